@@ -2,9 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/pkg/errors"
 
 	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
@@ -17,24 +19,71 @@ type Store interface {
 
 type Authorization interface {
 	IsAuthorizedAdmin(userID string) (bool, error)
+	IsAuthorizedPlugin(pluginID string) bool
+	CanManageLink(userID string, link autolink.Autolink) (bool, error)
+	CanDeleteLink(userID string, link autolink.Autolink) (bool, error)
+}
+
+// Auditor records the changes of links made or attempted through the API.
+// A nil before or after stands for a link that is added or deleted.
+type Auditor interface {
+	AuditLinkChange(userID, pluginID, action string, before, after *autolink.Autolink, allowed bool)
+}
+
+// Commands handles the requests made by the user interface of the /autolink
+// command: the submissions of the interactive dialogs it opens, and the
+// dynamic lists of its autocomplete.
+type Commands interface {
+	// SubmitLinkDialog handles a submitted link dialog. A nil response
+	// closes the dialog.
+	SubmitLinkDialog(request *model.SubmitDialogRequest) *model.SubmitDialogResponse
+
+	// Autocomplete returns the suggestions for the named autocomplete
+	// argument, as seen by userID.
+	Autocomplete(userID, argument string) ([]model.AutocompleteListItem, error)
+
+	// TestLinks applies all the enabled links to message, as if userID
+	// posted it in channelID, and traces each step. userID is empty for
+	// plugins. It returns ErrNotAuthorized if the user may not test links.
+	TestLinks(userID, channelID, message string) (*autolink.Trace, error)
+}
+
+// ErrNotAuthorized is returned by Commands when the user is not allowed to
+// do what was requested.
+var ErrNotAuthorized = errors.New("not authorized")
+
+// TestRequest is the body of a request to test the links on a message.
+type TestRequest struct {
+	Message   string `json:"message"`
+	ChannelID string `json:"channel_id"`
 }
 
 type Handler struct {
 	root          *mux.Router
 	store         Store
 	authorization Authorization
+	auditor       Auditor
+	commands      Commands
+	metrics       io.WriterTo
 }
 
-func NewHandler(store Store, authorization Authorization) *Handler {
+func NewHandler(store Store, authorization Authorization, auditor Auditor, commands Commands, metrics io.WriterTo) *Handler {
 	h := &Handler{
 		store:         store,
 		authorization: authorization,
+		auditor:       auditor,
+		commands:      commands,
+		metrics:       metrics,
 	}
 
 	root := mux.NewRouter()
 	api := root.PathPrefix("/api/v1").Subrouter()
-	api.Use(h.adminOrPluginRequired)
-	api.HandleFunc("/link", h.setLink).Methods("POST")
+	api.Handle("/link", h.userOrPluginRequired(http.HandlerFunc(h.setLink))).Methods("POST")
+	api.Handle("/link", h.userOrPluginRequired(http.HandlerFunc(h.deleteLink))).Methods("DELETE")
+	api.Handle("/metrics", h.adminOrPluginRequired(http.HandlerFunc(h.getMetrics))).Methods("GET")
+	api.Handle("/dialog", h.userRequired(http.HandlerFunc(h.submitDialog))).Methods("POST")
+	api.Handle("/autocomplete/{argument}", h.userRequired(http.HandlerFunc(h.autocomplete))).Methods("GET")
+	api.Handle("/test", h.userOrPluginRequired(http.HandlerFunc(h.testLinks))).Methods("POST")
 
 	api.Handle("{anything:.*}", http.NotFoundHandler())
 
@@ -62,8 +111,7 @@ func (h *Handler) adminOrPluginRequired(next http.Handler) http.Handler {
 		authorized := false
 		pluginID := r.Header.Get("Mattermost-Plugin-ID")
 		if pluginID != "" {
-			// All other plugins are allowed
-			authorized = true
+			authorized = h.authorization.IsAuthorizedPlugin(pluginID)
 		}
 
 		userID := r.Header.Get("Mattermost-User-ID")
@@ -83,6 +131,54 @@ func (h *Handler) adminOrPluginRequired(next http.Handler) http.Handler {
 	})
 }
 
+// userOrPluginRequired only lets through requests made by a user or an
+// allowed plugin, the handler is responsible for checking what they may
+// change.
+func (h *Handler) userOrPluginRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pluginID := r.Header.Get("Mattermost-Plugin-ID")
+		if pluginID != "" && !h.authorization.IsAuthorizedPlugin(pluginID) {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+		if pluginID == "" && r.Header.Get("Mattermost-User-ID") == "" {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userRequired only lets through requests made by a user, the handler is
+// responsible for checking what they may do.
+func (h *Handler) userRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Mattermost-User-ID") == "" {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canManageLink reports whether the request may create or change link.
+// Plugins may only change the links they own.
+func (h *Handler) canManageLink(r *http.Request, link autolink.Autolink) (bool, error) {
+	if pluginID := r.Header.Get("Mattermost-Plugin-ID"); pluginID != "" {
+		return link.OwnerPluginID == pluginID, nil
+	}
+	return h.authorization.CanManageLink(r.Header.Get("Mattermost-User-ID"), link)
+}
+
+// canDeleteLink reports whether the request may delete link. Plugins may
+// only delete the links they own.
+func (h *Handler) canDeleteLink(r *http.Request, link autolink.Autolink) (bool, error) {
+	if pluginID := r.Header.Get("Mattermost-Plugin-ID"); pluginID != "" {
+		return link.OwnerPluginID == pluginID, nil
+	}
+	return h.authorization.CanDeleteLink(r.Header.Get("Mattermost-User-ID"), link)
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.root.ServeHTTP(w, r)
 }
@@ -93,13 +189,43 @@ func (h *Handler) setLink(w http.ResponseWriter, r *http.Request) {
 		h.handleError(w, errors.Wrap(err, "unable to decode body"))
 		return
 	}
+	if err := newLink.CheckAction(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := newLink.CheckUserOptional(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Links created by a plugin are owned by it, users can't change the owner.
+	// Plugins may name the user who owns the link, links created by users are
+	// owned by them.
+	pluginID := r.Header.Get("Mattermost-Plugin-ID")
+	newLink.OwnerPluginID = pluginID
+	if pluginID == "" {
+		newLink.Owner = r.Header.Get("Mattermost-User-ID")
+	}
 
 	links := h.store.GetLinks()
+	var before *autolink.Autolink
 	found := false
 	changed := false
 	for i := range links {
 		if links[i].Name == newLink.Name || links[i].Pattern == newLink.Pattern {
+			if pluginID == "" {
+				newLink.OwnerPluginID = links[i].OwnerPluginID
+				newLink.Owner = links[i].Owner
+			} else if newLink.Owner == "" {
+				newLink.Owner = links[i].Owner
+			}
 			if !links[i].Equals(newLink) {
+				oldLink := links[i]
+				before = &oldLink
+				if !h.authorizeLinkChange(w, r, "set", before, &newLink, links[i]) ||
+					!h.authorizeLinkChange(w, r, "set", before, &newLink, newLink) {
+					return
+				}
 				links[i] = newLink
 				changed = true
 			}
@@ -107,7 +233,12 @@ func (h *Handler) setLink(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
+	action := "set"
 	if !found {
+		action = "add"
+		if !h.authorizeLinkChange(w, r, action, nil, &newLink, newLink) {
+			return
+		}
 		links = append(h.store.GetLinks(), newLink)
 		changed = true
 	}
@@ -117,6 +248,7 @@ func (h *Handler) setLink(w http.ResponseWriter, r *http.Request) {
 			h.handleError(w, errors.Wrap(err, "unable to save link"))
 			return
 		}
+		h.audit(r, action, before, &newLink, true)
 		status = http.StatusOK
 	}
 
@@ -124,3 +256,136 @@ func (h *Handler) setLink(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(status)
 	_, _ = w.Write([]byte(`{"status": "OK"}`))
 }
+
+func (h *Handler) deleteLink(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "The name of the link to delete is required", http.StatusBadRequest)
+		return
+	}
+
+	links := h.store.GetLinks()
+	newLinks := []autolink.Autolink{}
+	deleted := []autolink.Autolink{}
+	for _, l := range links {
+		if l.Name != name {
+			newLinks = append(newLinks, l)
+			continue
+		}
+
+		canDelete, err := h.canDeleteLink(r, l)
+		if err != nil {
+			h.handleError(w, errors.Wrap(err, "unable to authorize the request"))
+			return
+		}
+		if !canDelete {
+			h.audit(r, "delete", &l, nil, false)
+			http.Error(w, "Not authorized to delete this link", http.StatusForbidden)
+			return
+		}
+		deleted = append(deleted, l)
+	}
+	found := len(deleted) > 0
+	if !found {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.store.SaveLinks(newLinks); err != nil {
+		h.handleError(w, errors.Wrap(err, "unable to save links"))
+		return
+	}
+	for i := range deleted {
+		h.audit(r, "delete", &deleted[i], nil, true)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"status": "OK"}`))
+}
+
+// authorizeLinkChange writes an error response and returns false if the
+// request may not manage link. Denied changes from before to after are
+// audited.
+func (h *Handler) authorizeLinkChange(w http.ResponseWriter, r *http.Request, action string, before, after *autolink.Autolink, link autolink.Autolink) bool {
+	canManage, err := h.canManageLink(r, link)
+	if err != nil {
+		h.handleError(w, errors.Wrap(err, "unable to authorize the request"))
+		return false
+	}
+	if !canManage {
+		h.audit(r, action, before, after, false)
+		http.Error(w, "Not authorized to manage this link", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) audit(r *http.Request, action string, before, after *autolink.Autolink, allowed bool) {
+	h.auditor.AuditLinkChange(r.Header.Get("Mattermost-User-ID"), r.Header.Get("Mattermost-Plugin-ID"), action, before, after, allowed)
+}
+
+func (h *Handler) getMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = h.metrics.WriteTo(w)
+}
+
+func (h *Handler) submitDialog(w http.ResponseWriter, r *http.Request) {
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Unable to decode the dialog submission", http.StatusBadRequest)
+		return
+	}
+	// The submission is made on behalf of the user who sent the request
+	request.UserId = r.Header.Get("Mattermost-User-ID")
+
+	response := h.commands.SubmitLinkDialog(&request)
+	if response == nil {
+		response = &model.SubmitDialogResponse{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) autocomplete(w http.ResponseWriter, r *http.Request) {
+	items, err := h.commands.Autocomplete(r.Header.Get("Mattermost-User-ID"), mux.Vars(r)["argument"])
+	if err != nil {
+		h.handleError(w, errors.Wrap(err, "unable to list the suggestions"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+func (h *Handler) testLinks(w http.ResponseWriter, r *http.Request) {
+	var request TestRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.handleError(w, errors.Wrap(err, "unable to decode body"))
+		return
+	}
+
+	// Plugins test the links as if they were posted by nobody in particular
+	userID := ""
+	if r.Header.Get("Mattermost-Plugin-ID") == "" {
+		userID = r.Header.Get("Mattermost-User-ID")
+	}
+
+	trace, err := h.commands.TestLinks(userID, request.ChannelID, request.Message)
+	if errors.Is(err, ErrNotAuthorized) {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		h.handleError(w, errors.Wrap(err, "unable to test the links"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(trace)
+}
@@ -6,10 +6,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/metrics"
 )
 
 type authorizeAll struct{}
@@ -18,6 +23,37 @@ func (authorizeAll) IsAuthorizedAdmin(string) (bool, error) {
 	return true, nil
 }
 
+func (authorizeAll) IsAuthorizedPlugin(string) bool {
+	return true
+}
+
+func (authorizeAll) CanManageLink(string, autolink.Autolink) (bool, error) {
+	return true, nil
+}
+
+func (authorizeAll) CanDeleteLink(string, autolink.Autolink) (bool, error) {
+	return true, nil
+}
+
+// authorizeTeamAdmin authorizes changes to links scoped to "team" only.
+type authorizeTeamAdmin struct{}
+
+func (authorizeTeamAdmin) IsAuthorizedAdmin(string) (bool, error) {
+	return false, nil
+}
+
+func (authorizeTeamAdmin) IsAuthorizedPlugin(string) bool {
+	return false
+}
+
+func (authorizeTeamAdmin) CanManageLink(_ string, link autolink.Autolink) (bool, error) {
+	return len(link.Scope) == 1 && link.Scope[0] == "team", nil
+}
+
+func (a authorizeTeamAdmin) CanDeleteLink(userID string, link autolink.Autolink) (bool, error) {
+	return a.CanManageLink(userID, link)
+}
+
 type linkStore struct {
 	prev       []autolink.Autolink
 	saveCalled *bool
@@ -34,6 +70,50 @@ func (s *linkStore) SaveLinks(links []autolink.Autolink) error {
 	return nil
 }
 
+type auditedChange struct {
+	action  string
+	link    string
+	allowed bool
+}
+
+type recordingAuditor struct {
+	changes []auditedChange
+}
+
+func (a *recordingAuditor) AuditLinkChange(_, _, action string, before, after *autolink.Autolink, allowed bool) {
+	link := ""
+	switch {
+	case after != nil:
+		link = after.Name
+	case before != nil:
+		link = before.Name
+	}
+	a.changes = append(a.changes, auditedChange{action: action, link: link, allowed: allowed})
+}
+
+type commands struct{}
+
+func (commands) SubmitLinkDialog(request *model.SubmitDialogRequest) *model.SubmitDialogResponse {
+	if request.Submission["pattern"] == "" {
+		return &model.SubmitDialogResponse{Errors: map[string]string{"pattern": "required by " + request.UserId}}
+	}
+	return nil
+}
+
+func (commands) Autocomplete(userID, argument string) ([]model.AutocompleteListItem, error) {
+	if argument != "links" {
+		return nil, errors.Errorf("unknown argument %q", argument)
+	}
+	return []model.AutocompleteListItem{{Item: "Jira", Hint: "for " + userID}}, nil
+}
+
+func (commands) TestLinks(userID, channelID, message string) (*autolink.Trace, error) {
+	if userID == "denied" {
+		return nil, ErrNotAuthorized
+	}
+	return &autolink.Trace{Message: message, Result: userID + " in " + channelID}, nil
+}
+
 func TestSetLink(t *testing.T) {
 	for _, tc := range []struct {
 		name             string
@@ -52,7 +132,8 @@ func TestSetLink(t *testing.T) {
 			expectStatus:     http.StatusOK,
 			expectSaveCalled: true,
 			expectSaved: []autolink.Autolink{{
-				Name: "test",
+				Name:          "test",
+				OwnerPluginID: "testfrom",
 			}},
 		},
 		{
@@ -74,9 +155,10 @@ func TestSetLink(t *testing.T) {
 				Pattern:  ".*2",
 				Template: "test2",
 			}, {
-				Name:     "test1",
-				Pattern:  ".*1",
-				Template: "test1",
+				Name:          "test1",
+				Pattern:       ".*1",
+				Template:      "test1",
+				OwnerPluginID: "testfrom",
 			}},
 		}, {
 			name: "replace link",
@@ -90,9 +172,10 @@ func TestSetLink(t *testing.T) {
 				Pattern:  ".*1",
 				Template: "test1",
 			}, {
-				Name:     "test2",
-				Pattern:  ".*2",
-				Template: "test2",
+				Name:          "test2",
+				Pattern:       ".*2",
+				Template:      "test2",
+				OwnerPluginID: "testfrom",
 			}, {
 				Name:     "test3",
 				Pattern:  ".*3",
@@ -105,9 +188,10 @@ func TestSetLink(t *testing.T) {
 				Pattern:  ".*1",
 				Template: "test1",
 			}, {
-				Name:     "test2",
-				Pattern:  ".*2",
-				Template: "new template",
+				Name:          "test2",
+				Pattern:       ".*2",
+				Template:      "new template",
+				OwnerPluginID: "testfrom",
 			}, {
 				Name:     "test3",
 				Pattern:  ".*3",
@@ -126,11 +210,100 @@ func TestSetLink(t *testing.T) {
 				Pattern:  ".*1",
 				Template: "test1",
 			}, {
+				Name:          "test2",
+				Pattern:       ".*2",
+				Template:      "test2",
+				OwnerPluginID: "testfrom",
+			}},
+			expectStatus:     http.StatusNotModified,
+			expectSaveCalled: false,
+		}, {
+			name: "link owned by another plugin",
+			link: autolink.Autolink{
+				Name:     "test2",
+				Pattern:  ".*2",
+				Template: "new template",
+			},
+			prevLinks: []autolink.Autolink{{
+				Name:          "test2",
+				Pattern:       ".*2",
+				Template:      "test2",
+				OwnerPluginID: "otherplugin",
+			}},
+			expectStatus:     http.StatusForbidden,
+			expectSaveCalled: false,
+		}, {
+			name: "link owned by a user",
+			link: autolink.Autolink{
+				Name:     "test2",
+				Pattern:  ".*2",
+				Template: "new template",
+			},
+			prevLinks: []autolink.Autolink{{
 				Name:     "test2",
 				Pattern:  ".*2",
 				Template: "test2",
+				Owner:    "someuser",
 			}},
-			expectStatus:     http.StatusNotModified,
+			expectStatus:     http.StatusForbidden,
+			expectSaveCalled: false,
+		}, {
+			name: "link without an owner",
+			link: autolink.Autolink{
+				Name:     "test2",
+				Pattern:  ".*2",
+				Template: "new template",
+			},
+			prevLinks: []autolink.Autolink{{
+				Name:     "test2",
+				Pattern:  ".*2",
+				Template: "test2",
+			}},
+			expectStatus:     http.StatusForbidden,
+			expectSaveCalled: false,
+		}, {
+			name: "link with the same pattern owned by another plugin",
+			link: autolink.Autolink{
+				Name:     "test2",
+				Pattern:  ".*1",
+				Template: "test2",
+			},
+			prevLinks: []autolink.Autolink{{
+				Name:          "test1",
+				Pattern:       ".*1",
+				Template:      "test1",
+				OwnerPluginID: "otherplugin",
+			}},
+			expectStatus:     http.StatusForbidden,
+			expectSaveCalled: false,
+		}, {
+			name: "link matched by pattern",
+			link: autolink.Autolink{
+				Pattern:  ".*1",
+				Template: "new template",
+			},
+			prevLinks: []autolink.Autolink{{
+				Pattern:       ".*1",
+				Template:      "test1",
+				OwnerPluginID: "testfrom",
+			}},
+			expectStatus:     http.StatusOK,
+			expectSaveCalled: true,
+			expectSaved: []autolink.Autolink{{
+				Pattern:       ".*1",
+				Template:      "new template",
+				OwnerPluginID: "testfrom",
+			}},
+		}, {
+			name: "mandatory link marked UserOptional",
+			link: autolink.Autolink{
+				Name:         "test",
+				Pattern:      ".*",
+				Template:     "test",
+				Action:       autolink.ActionRedact,
+				UserOptional: true,
+			},
+			expectStatus:     http.StatusBadRequest,
 			expectSaveCalled: false,
 		},
 	} {
@@ -145,6 +318,9 @@ func TestSetLink(t *testing.T) {
 					saved:      &saved,
 				},
 				authorizeAll{},
+				&recordingAuditor{},
+				commands{},
+				metrics.New(),
 			)
 
 			body, err := json.Marshal(tc.link)
@@ -168,3 +344,335 @@ func TestSetLink(t *testing.T) {
 		})
 	}
 }
+
+func TestSetLinkDelegated(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		prevLinks        []autolink.Autolink
+		link             autolink.Autolink
+		expectSaveCalled bool
+		expectStatus     int
+		expectAudited    []auditedChange
+	}{
+		{
+			name: "link in own team",
+			link: autolink.Autolink{
+				Name:  "test",
+				Scope: []string{"team"},
+			},
+			expectStatus:     http.StatusOK,
+			expectSaveCalled: true,
+			expectAudited:    []auditedChange{{action: "add", link: "test", allowed: true}},
+		}, {
+			name: "unscoped link",
+			link: autolink.Autolink{
+				Name: "test",
+			},
+			expectStatus:  http.StatusForbidden,
+			expectAudited: []auditedChange{{action: "add", link: "test", allowed: false}},
+		}, {
+			name: "replace link of another team",
+			link: autolink.Autolink{
+				Name:     "test",
+				Template: "new template",
+				Scope:    []string{"team"},
+			},
+			prevLinks: []autolink.Autolink{{
+				Name:     "test",
+				Template: "test",
+				Scope:    []string{"other-team"},
+			}},
+			expectStatus:  http.StatusForbidden,
+			expectAudited: []auditedChange{{action: "set", link: "test", allowed: false}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var saved []autolink.Autolink
+			var saveCalled bool
+			auditor := &recordingAuditor{}
+
+			h := NewHandler(
+				&linkStore{
+					prev:       tc.prevLinks,
+					saveCalled: &saveCalled,
+					saved:      &saved,
+				},
+				authorizeTeamAdmin{},
+				auditor,
+				commands{},
+				metrics.New(),
+			)
+
+			body, err := json.Marshal(tc.link)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("POST", "/api/v1/link", bytes.NewReader(body))
+			require.NoError(t, err)
+			r.Header.Set("Mattermost-User-ID", "testuser")
+
+			h.ServeHTTP(w, r)
+			require.Equal(t, tc.expectStatus, w.Code)
+			require.Equal(t, tc.expectSaveCalled, saveCalled)
+			require.Equal(t, tc.expectAudited, auditor.changes)
+		})
+	}
+}
+
+func TestSetLinkOwner(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		prevLinks   []autolink.Autolink
+		link        autolink.Autolink
+		pluginID    string
+		expectOwner string
+	}{
+		{
+			name:        "new link is owned by the user",
+			link:        autolink.Autolink{Name: "test", Owner: "someone-else"},
+			expectOwner: "testuser",
+		}, {
+			name:        "owner is kept on update",
+			prevLinks:   []autolink.Autolink{{Name: "test", Owner: "owner"}},
+			link:        autolink.Autolink{Name: "test", Template: "new"},
+			expectOwner: "owner",
+		}, {
+			name:        "plugin names the owner",
+			link:        autolink.Autolink{Name: "test", Owner: "owner"},
+			pluginID:    "testfrom",
+			expectOwner: "owner",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var saved []autolink.Autolink
+			var saveCalled bool
+
+			h := NewHandler(
+				&linkStore{
+					prev:       tc.prevLinks,
+					saveCalled: &saveCalled,
+					saved:      &saved,
+				},
+				authorizeAll{},
+				&recordingAuditor{},
+				commands{},
+				metrics.New(),
+			)
+
+			body, err := json.Marshal(tc.link)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("POST", "/api/v1/link", bytes.NewReader(body))
+			require.NoError(t, err)
+			r.Header.Set("Mattermost-User-ID", "testuser")
+			if tc.pluginID != "" {
+				r.Header.Set("Mattermost-Plugin-ID", tc.pluginID)
+			}
+
+			h.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Len(t, saved, 1)
+			require.Equal(t, tc.expectOwner, saved[0].Owner)
+		})
+	}
+}
+
+func TestPluginNotAllowed(t *testing.T) {
+	var saved []autolink.Autolink
+	var saveCalled bool
+
+	h := NewHandler(
+		&linkStore{
+			saveCalled: &saveCalled,
+			saved:      &saved,
+		},
+		authorizeTeamAdmin{},
+		&recordingAuditor{},
+		commands{},
+		metrics.New(),
+	)
+
+	body, err := json.Marshal(autolink.Autolink{Name: "test", Scope: []string{"team"}})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("POST", "/api/v1/link", bytes.NewReader(body))
+	require.NoError(t, err)
+	r.Header.Set("Mattermost-Plugin-ID", "testfrom")
+
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.False(t, saveCalled)
+}
+
+func TestDeleteLink(t *testing.T) {
+	prevLinks := []autolink.Autolink{{
+		Name:          "owned",
+		OwnerPluginID: "testfrom",
+	}, {
+		Name:          "other",
+		OwnerPluginID: "otherplugin",
+	}}
+
+	for _, tc := range []struct {
+		name             string
+		linkName         string
+		expectStatus     int
+		expectSaveCalled bool
+		expectSaved      []autolink.Autolink
+		expectAudited    []auditedChange
+	}{
+		{
+			name:             "owned link",
+			linkName:         "owned",
+			expectStatus:     http.StatusOK,
+			expectSaveCalled: true,
+			expectSaved:      prevLinks[1:],
+			expectAudited:    []auditedChange{{action: "delete", link: "owned", allowed: true}},
+		}, {
+			name:          "link owned by another plugin",
+			linkName:      "other",
+			expectStatus:  http.StatusForbidden,
+			expectAudited: []auditedChange{{action: "delete", link: "other", allowed: false}},
+		}, {
+			name:         "not found",
+			linkName:     "missing",
+			expectStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var saved []autolink.Autolink
+			var saveCalled bool
+			auditor := &recordingAuditor{}
+
+			h := NewHandler(
+				&linkStore{
+					prev:       prevLinks,
+					saveCalled: &saveCalled,
+					saved:      &saved,
+				},
+				authorizeAll{},
+				auditor,
+				commands{},
+				metrics.New(),
+			)
+
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("DELETE", "/api/v1/link?name="+tc.linkName, nil)
+			require.NoError(t, err)
+			r.Header.Set("Mattermost-Plugin-ID", "testfrom")
+
+			h.ServeHTTP(w, r)
+			require.Equal(t, tc.expectStatus, w.Code)
+			require.Equal(t, tc.expectSaveCalled, saveCalled)
+			require.Equal(t, tc.expectSaved, saved)
+			require.Equal(t, tc.expectAudited, auditor.changes)
+		})
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	m := metrics.New()
+	m.ObserveProcessPost(time.Millisecond)
+	m.IncPostsRewritten()
+
+	h := NewHandler(&linkStore{}, authorizeAll{}, &recordingAuditor{}, commands{}, m)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/api/v1/metrics", nil)
+	require.NoError(t, err)
+	r.Header.Set("Mattermost-User-ID", "testuser")
+
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, w.Body.String(), "autolink_process_post_duration_seconds_count 1\n")
+	assert.Contains(t, w.Body.String(), "autolink_posts_rewritten_total 1\n")
+}
+
+func TestSubmitDialog(t *testing.T) {
+	h := NewHandler(&linkStore{}, authorizeAll{}, &recordingAuditor{}, commands{}, metrics.New())
+
+	submit := func(userID string, submission map[string]any) *httptest.ResponseRecorder {
+		body, err := json.Marshal(model.SubmitDialogRequest{
+			UserId:     "spoofed",
+			Submission: submission,
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("POST", "/api/v1/dialog", bytes.NewReader(body))
+		require.NoError(t, err)
+		if userID != "" {
+			r.Header.Set("Mattermost-User-ID", userID)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := submit("", map[string]any{"pattern": "(x)"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = submit("testuser", map[string]any{"pattern": "(x)"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{}`, w.Body.String())
+
+	w = submit("testuser", map[string]any{"pattern": ""})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"errors": {"pattern": "required by testuser"}}`, w.Body.String())
+}
+
+func TestAutocomplete(t *testing.T) {
+	h := NewHandler(&linkStore{}, authorizeAll{}, &recordingAuditor{}, commands{}, metrics.New())
+
+	get := func(userID, argument string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/api/v1/autocomplete/"+argument, nil)
+		require.NoError(t, err)
+		if userID != "" {
+			r.Header.Set("Mattermost-User-ID", userID)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("", "links")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = get("testuser", "links")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"Item": "Jira", "Hint": "for testuser", "HelpText": ""}]`, w.Body.String())
+
+	w = get("testuser", "unknown")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestTestLinks(t *testing.T) {
+	h := NewHandler(&linkStore{}, authorizeAll{}, &recordingAuditor{}, commands{}, metrics.New())
+
+	post := func(header, value string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("POST", "/api/v1/test", bytes.NewReader([]byte(`{"message": "MM-1", "channel_id": "town-square"}`)))
+		require.NoError(t, err)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = post("Mattermost-User-ID", "denied")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = post("Mattermost-User-ID", "testuser")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"Message": "MM-1", "Result": "testuser in town-square"}`, w.Body.String())
+
+	w = post("Mattermost-Plugin-ID", "somePlugin")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"Message": "MM-1", "Result": " in town-square"}`, w.Body.String())
+}
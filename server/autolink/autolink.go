@@ -1,10 +1,39 @@
 package autolink
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 )
 
+// EscapePrefix placed immediately before a match prevents it from being
+// replaced, when HonorEscape is set. The prefix itself is removed. Mandatory
+// links ignore it.
+const EscapePrefix = `\`
+
+const (
+	nonWordPrefixGroup = "MattermostNonWordPrefix"
+	nonWordSuffixGroup = "MattermostNonWordSuffix"
+)
+
+// Actions of the links other than replacing the matches.
+const (
+	// ActionRedact is the Action of the links that mask sensitive data, such
+	// as card numbers. The author of a redacted post is told about it, and
+	// the original message is not kept to revert the post.
+	ActionRedact = "redact"
+	// ActionReject is the Action of the links that match data that must not
+	// be posted at all, such as secret keys. The post is rejected with the
+	// template as the reason.
+	ActionReject = "reject"
+)
+
+// variableRegexp matches the ${var.NAME} references in a template, and the
+// escaped dollar signs in front of which they are not references.
+var variableRegexp = regexp.MustCompile(`\$(\$|\{var\.([A-Za-z0-9_]+)\})`)
+
 // Autolink represents a pattern to autolink.
 type Autolink struct {
 	Name                 string   `json:"Name"`
@@ -15,18 +44,58 @@ type Autolink struct {
 	WordMatch            bool     `json:"WordMatch"`
 	DisableNonWordPrefix bool     `json:"DisableNonWordPrefix"`
 	DisableNonWordSuffix bool     `json:"DisableNonWordSuffix"`
-	ProcessBotPosts      bool     `json:"ProcessBotPosts"`
+	// ProcessBotPosts is unset to inherit the setting of the group, see
+	// ProcessesBotPosts.
+	ProcessBotPosts *bool  `json:"ProcessBotPosts,omitempty"`
+	UserOptional    bool   `json:"UserOptional"`
+	OwnerPluginID   string `json:"OwnerPluginID"`
+	Owner           string `json:"Owner"`
+	Group           string `json:"Group"`
+
+	// ActiveFrom and ActiveUntil limit the time the link is applied, either
+	// can be left unset. Links are not applied from ActiveUntil on.
+	ActiveFrom  *time.Time `json:"ActiveFrom,omitempty"`
+	ActiveUntil *time.Time `json:"ActiveUntil,omitempty"`
+
+	// Action is what the link does to the posts it matches, the matches are
+	// replaced with the template if it is empty.
+	Action string `json:"Action"`
+	// AuditRedactions records each post redacted by the link in the audit
+	// log.
+	AuditRedactions bool `json:"AuditRedactions"`
+
+	// HonorEscape is not stored with the link, it is set from the plugin
+	// configuration before Compile is called.
+	HonorEscape bool `json:"-"`
+
+	// Variables are the values of the ${var.NAME} references in the
+	// template. Like HonorEscape, they are set from the plugin configuration
+	// before Compile is called.
+	Variables map[string]string `json:"-"`
+
+	// GroupDefaults are the settings of the group named by Group, that the
+	// link inherits. They are set from the plugin configuration too.
+	GroupDefaults *LinkGroup `json:"-"`
 
 	template      string
 	re            *regexp.Regexp
 	canReplaceAll bool
+	honorEscape   bool
 }
 
 func (l Autolink) Equals(x Autolink) bool {
 	if l.Disabled != x.Disabled ||
 		l.DisableNonWordPrefix != x.DisableNonWordPrefix ||
 		l.DisableNonWordSuffix != x.DisableNonWordSuffix ||
-		l.ProcessBotPosts != x.ProcessBotPosts ||
+		!equalBools(l.ProcessBotPosts, x.ProcessBotPosts) ||
+		l.UserOptional != x.UserOptional ||
+		l.OwnerPluginID != x.OwnerPluginID ||
+		l.Owner != x.Owner ||
+		l.Group != x.Group ||
+		l.Action != x.Action ||
+		l.AuditRedactions != x.AuditRedactions ||
+		!equalTimes(l.ActiveFrom, x.ActiveFrom) ||
+		!equalTimes(l.ActiveUntil, x.ActiveUntil) ||
 		l.Name != x.Name ||
 		l.Pattern != x.Pattern ||
 		len(l.Scope) != len(x.Scope) ||
@@ -42,6 +111,34 @@ func (l Autolink) Equals(x Autolink) bool {
 	return true
 }
 
+func equalBools(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// ProcessesBotPosts reports whether the link is applied to the posts made by
+// bot accounts. Links that don't set ProcessBotPosts are not, unless they
+// inherit it from their group, see Effective.
+func (l Autolink) ProcessesBotPosts() bool {
+	return l.ProcessBotPosts != nil && *l.ProcessBotPosts
+}
+
+// IsActive reports whether the link is applied at t, according to
+// ActiveFrom and ActiveUntil.
+func (l Autolink) IsActive(t time.Time) bool {
+	return (l.ActiveFrom == nil || !t.Before(*l.ActiveFrom)) &&
+		(l.ActiveUntil == nil || t.Before(*l.ActiveUntil))
+}
+
 // DisplayName returns a display name for the link.
 func (l Autolink) DisplayName() string {
 	if l.Name != "" {
@@ -52,23 +149,38 @@ func (l Autolink) DisplayName() string {
 
 // Compile compiles the link's regular expression
 func (l *Autolink) Compile() error {
-	if l.Disabled || len(l.Pattern) == 0 || len(l.Template) == 0 {
+	if l.Effective().Disabled || len(l.Pattern) == 0 || len(l.Template) == 0 {
 		return nil
 	}
+	if err := l.CheckAction(); err != nil {
+		return err
+	}
+
+	template, err := l.expandVariables()
+	if err != nil {
+		return err
+	}
+	l.honorEscape = l.HonorEscape && !l.IsMandatory()
 
 	// `\b` can be used with ReplaceAll since it does not consume characters,
 	// custom patterns can not and need to be processed one at a time.
 	canReplaceAll := false
 	pattern := l.Pattern
-	template := l.Template
 	replacingCharacter := `\b`
 	if !l.DisableNonWordPrefix {
 		if l.WordMatch {
 			pattern = fmt.Sprint(replacingCharacter, pattern)
 			canReplaceAll = true
 		} else {
-			pattern = `(?P<MattermostNonWordPrefix>(^|\s))` + pattern
-			template = `${MattermostNonWordPrefix}` + template
+			prefix := `^|\s`
+			if l.HonorEscape || l.IsMandatory() {
+				// Match escaped text too, so that the escape prefix can be
+				// removed, or so that it can't hide the data of a mandatory
+				// link, which keeps it
+				prefix += `|` + regexp.QuoteMeta(EscapePrefix)
+			}
+			pattern = `(?P<` + nonWordPrefixGroup + `>(` + prefix + `))` + pattern
+			template = `${` + nonWordPrefixGroup + `}` + template
 		}
 	}
 	if !l.DisableNonWordSuffix {
@@ -76,8 +188,8 @@ func (l *Autolink) Compile() error {
 			pattern += replacingCharacter
 			canReplaceAll = true
 		} else {
-			pattern += `(?P<MattermostNonWordSuffix>$|[\s\.\!\?\,\)])`
-			template += `${MattermostNonWordSuffix}`
+			pattern += `(?P<` + nonWordSuffixGroup + `>$|[\s\.\!\?\,\)])`
+			template += `${` + nonWordSuffixGroup + `}`
 		}
 	}
 
@@ -92,6 +204,97 @@ func (l *Autolink) Compile() error {
 	return nil
 }
 
+// IsMandatory reports whether the link redacts or rejects what it matches.
+// Such links are applied whatever the escape prefix, and to the posts opted
+// out of the other links.
+func (l Autolink) IsMandatory() bool {
+	return l.Action == ActionRedact || l.Action == ActionReject
+}
+
+// IsUserOptional reports whether users can turn the link off for their own
+// posts. Mandatory links can't be turned off, even if marked UserOptional.
+func (l Autolink) IsUserOptional() bool {
+	return l.UserOptional && !l.IsMandatory()
+}
+
+// CheckAction returns an error if the Action of the link is not supported.
+func (l Autolink) CheckAction() error {
+	if l.Action != "" && l.Action != ActionRedact && l.Action != ActionReject {
+		return fmt.Errorf("%q is not a valid action, must be %q, %q or empty to replace the matches", l.Action, ActionRedact, ActionReject)
+	}
+	return nil
+}
+
+// CheckUserOptional returns an error if the link is mandatory and marked
+// UserOptional. Such a link is still applied to every post, it is refused
+// when saved so that its settings say what it does.
+func (l Autolink) CheckUserOptional() error {
+	if l.UserOptional && l.IsMandatory() {
+		return fmt.Errorf("links that %s can't be UserOptional, users must not be able to turn them off", l.Action)
+	}
+	return nil
+}
+
+// expandVariables returns the template with the ${var.NAME} references
+// replaced with the values of the variables, which are used as is.
+func (l Autolink) expandVariables() (string, error) {
+	var err error
+	template := variableRegexp.ReplaceAllStringFunc(l.Template, func(ref string) string {
+		name := variableRegexp.FindStringSubmatch(ref)[2]
+		if name == "" {
+			return ref
+		}
+		value, ok := LookupVariable(l.Variables, name)
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("the template uses the undefined variable %q", name)
+			}
+			return ref
+		}
+		return strings.ReplaceAll(value, "$", "$$")
+	})
+	return template, err
+}
+
+// VariableNames returns the names of the variables the template references,
+// in order, without duplicates.
+func (l Autolink) VariableNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, ref := range variableRegexp.FindAllStringSubmatch(l.Template, -1) {
+		if name := ref[2]; name != "" && !seen[strings.ToUpper(name)] {
+			seen[strings.ToUpper(name)] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// LookupVariable returns the value of the variable name. Names are not case
+// sensitive, an exact match is preferred.
+func LookupVariable(variables map[string]string, name string) (string, bool) {
+	if value, ok := variables[name]; ok {
+		return value, true
+	}
+	for n, value := range variables {
+		if strings.EqualFold(n, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// EffectivePattern returns the regular expression the link was compiled to,
+// including what Compile added around the pattern: \b for WordMatch, or the
+// groups matching the whitespace and punctuation around it. It is empty if
+// the link is not compiled.
+func (l Autolink) EffectivePattern() string {
+	if l.re == nil {
+		return ""
+	}
+	return l.re.String()
+}
+
 // Replace will subsitute the regex's with the supplied links
 func (l Autolink) Replace(message string) string {
 	if l.re == nil {
@@ -99,10 +302,13 @@ func (l Autolink) Replace(message string) string {
 	}
 
 	// Since they don't consume, `\b`s require no special handling, can just ReplaceAll
-	if l.canReplaceAll {
+	if l.canReplaceAll && !(l.honorEscape && strings.Contains(message, EscapePrefix)) {
 		return l.re.ReplaceAllString(message, l.template)
 	}
 
+	prefixIndex := l.re.SubexpIndex(nonWordPrefixGroup)
+	escape := []byte(EscapePrefix)
+
 	// Replace one at a time
 	in := []byte(message)
 	out := []byte{}
@@ -116,6 +322,20 @@ func (l Autolink) Replace(message string) string {
 			break
 		}
 
+		if l.honorEscape {
+			// The match proper starts after the non-word prefix, if any
+			start := submatch[0]
+			if prefixIndex > 0 && submatch[2*prefixIndex+1] >= 0 {
+				start = submatch[2*prefixIndex+1]
+			}
+			if start >= len(escape) && bytes.Equal(in[start-len(escape):start], escape) {
+				out = append(out, in[:start-len(escape)]...)
+				out = append(out, in[start:submatch[1]]...)
+				in = in[submatch[1]:]
+				continue
+			}
+		}
+
 		out = append(out, in[:submatch[0]]...)
 		out = l.re.Expand(out, []byte(l.template), in, submatch)
 		in = in[submatch[1]:]
@@ -124,21 +344,42 @@ func (l Autolink) Replace(message string) string {
 	return string(out)
 }
 
-// ToMarkdown prints a Link as a markdown list element
+// Rejection returns the template expanded with the first match of the link in
+// message, and whether there is one. It is the reason a reject link rejects
+// the message.
+func (l Autolink) Rejection(message string) (string, bool) {
+	matches := l.Matches(message)
+	if len(matches) == 0 {
+		return "", false
+	}
+	// Replacing the match alone expands the template with its groups
+	return l.Replace(matches[0].Text), true
+}
+
+// ToMarkdown prints a Link as a markdown list element, with the effective
+// values of the settings inherited from its group.
 func (l Autolink) ToMarkdown(i int) string {
+	e := l.Effective()
+	fromGroup := func(field string) string {
+		if l.inherits(field) {
+			return fmt.Sprintf(" (from group `%s`)", l.Group)
+		}
+		return ""
+	}
+
 	text := "- "
 	if i > 0 {
 		text += fmt.Sprintf("%v: ", i)
 	}
 	if l.Name != "" {
-		if l.Disabled {
+		if e.Disabled {
 			text += fmt.Sprintf("~~%s~~", l.Name)
 		} else {
 			text += l.Name
 		}
 	}
-	if l.Disabled {
-		text += " **Disabled**"
+	if e.Disabled {
+		text += " **Disabled**" + fromGroup("Disabled")
 	}
 	text += "\n"
 
@@ -151,14 +392,39 @@ func (l Autolink) ToMarkdown(i int) string {
 	if l.DisableNonWordSuffix {
 		text += fmt.Sprintf("  - DisableNonWordSuffix: `%v`\n", l.DisableNonWordSuffix)
 	}
-	if l.ProcessBotPosts {
-		text += fmt.Sprintf("  - ProcessBotPosts: `%v`\n", l.ProcessBotPosts)
+	if l.Group != "" {
+		text += fmt.Sprintf("  - Group: `%v`\n", l.Group)
+	}
+	// Group members show the value that overrides their group's
+	if e.ProcessesBotPosts() || (l.ProcessBotPosts != nil && l.GroupDefaults != nil) {
+		text += fmt.Sprintf("  - ProcessBotPosts: `%v`%s\n", e.ProcessesBotPosts(), fromGroup("ProcessBotPosts"))
 	}
-	if len(l.Scope) != 0 {
-		text += fmt.Sprintf("  - Scope: `%v`\n", l.Scope)
+	if len(e.Scope) != 0 {
+		text += fmt.Sprintf("  - Scope: `%v`%s\n", e.Scope, fromGroup("Scope"))
 	}
 	if l.WordMatch {
 		text += fmt.Sprintf("  - WordMatch: `%v`\n", l.WordMatch)
 	}
+	if l.UserOptional {
+		text += fmt.Sprintf("  - UserOptional: `%v`\n", l.UserOptional)
+	}
+	if l.ActiveFrom != nil {
+		text += fmt.Sprintf("  - ActiveFrom: `%v`\n", l.ActiveFrom.Format(time.RFC3339))
+	}
+	if l.ActiveUntil != nil {
+		text += fmt.Sprintf("  - ActiveUntil: `%v`\n", l.ActiveUntil.Format(time.RFC3339))
+	}
+	if l.Action != "" {
+		text += fmt.Sprintf("  - Action: `%v`\n", l.Action)
+	}
+	if l.AuditRedactions {
+		text += fmt.Sprintf("  - AuditRedactions: `%v`\n", l.AuditRedactions)
+	}
+	if l.OwnerPluginID != "" {
+		text += fmt.Sprintf("  - OwnerPluginID: `%v`\n", l.OwnerPluginID)
+	}
+	if l.Owner != "" {
+		text += fmt.Sprintf("  - Owner: `%v`\n", l.Owner)
+	}
 	return text
 }
@@ -366,3 +366,194 @@ func TestWildcard(t *testing.T) {
 		assert.Equal(t, "My template", post.Message)
 	}
 }
+
+func TestEscape(t *testing.T) {
+	const pattern = "(KEY)(-)(?P<ID>\\d+)"
+	const template = "[KEY-$ID](someurl/KEY-$ID)"
+
+	for _, tc := range []struct {
+		Name     string
+		Link     autolink.Autolink
+		Message  string
+		Expected string
+	}{
+		{
+			Name:     "escaped match is left untouched",
+			Link:     autolink.Autolink{Pattern: pattern, Template: template, HonorEscape: true},
+			Message:  `see \KEY-1 and KEY-2`,
+			Expected: "see KEY-1 and [KEY-2](someurl/KEY-2)",
+		}, {
+			Name:     "escaped match at the start",
+			Link:     autolink.Autolink{Pattern: pattern, Template: template, HonorEscape: true},
+			Message:  `\KEY-1`,
+			Expected: "KEY-1",
+		}, {
+			Name:     "escaped word match",
+			Link:     autolink.Autolink{Pattern: pattern, Template: template, WordMatch: true, HonorEscape: true},
+			Message:  `(\KEY-1) (KEY-2)`,
+			Expected: "(KEY-1) ([KEY-2](someurl/KEY-2))",
+		}, {
+			Name:     "escaped match without prefix",
+			Link:     autolink.Autolink{Pattern: pattern, Template: template, DisableNonWordPrefix: true, HonorEscape: true},
+			Message:  `a\KEY-1 aKEY-2`,
+			Expected: "aKEY-1 a[KEY-2](someurl/KEY-2)",
+		}, {
+			Name:     "escape is ignored when not honored",
+			Link:     autolink.Autolink{Pattern: pattern, Template: template, WordMatch: true},
+			Message:  `\KEY-1`,
+			Expected: `\[KEY-1](someurl/KEY-1)`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			l := tc.Link
+			require.NoError(t, l.Compile())
+			assert.Equal(t, tc.Expected, l.Replace(tc.Message))
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	const pattern = "(?P<key>MM-\\d+)"
+	variables := map[string]string{"JIRA_URL": "https://jira.example.com", "Price": "$5"}
+
+	for _, tc := range []struct {
+		Name          string
+		Template      string
+		Message       string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "variable",
+			Template: "[$key](${var.JIRA_URL}/browse/$key)",
+			Message:  "see MM-1",
+			Expected: "see [MM-1](https://jira.example.com/browse/MM-1)",
+		}, {
+			Name:     "names are not case sensitive",
+			Template: "[$key](${var.jira_url}/browse/$key)",
+			Message:  "see MM-1",
+			Expected: "see [MM-1](https://jira.example.com/browse/MM-1)",
+		}, {
+			Name:     "values are used as is",
+			Template: "$key costs ${var.Price}",
+			Message:  "MM-1",
+			Expected: "MM-1 costs $5",
+		}, {
+			Name:     "escaped reference",
+			Template: "$key $${var.Price}",
+			Message:  "MM-1",
+			Expected: "MM-1 ${var.Price}",
+		}, {
+			Name:          "undefined variable",
+			Template:      "[$key](${var.ZENDESK_URL}/$key)",
+			ExpectedError: `the template uses the undefined variable "ZENDESK_URL"`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			l := autolink.Autolink{Pattern: pattern, Template: tc.Template, Variables: variables}
+			err := l.Compile()
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, l.Replace(tc.Message))
+		})
+	}
+}
+
+func TestVariableNames(t *testing.T) {
+	l := autolink.Autolink{Template: "${var.JIRA_URL}/$key ${var.jira_url} $${var.ESCAPED} ${var.Team}"}
+	assert.Equal(t, []string{"JIRA_URL", "Team"}, l.VariableNames())
+	assert.Empty(t, autolink.Autolink{Template: "$key"}.VariableNames())
+}
+
+func TestIsActive(t *testing.T) {
+	from := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	until := from.Add(24 * time.Hour)
+
+	assert.True(t, autolink.Autolink{}.IsActive(from))
+
+	l := autolink.Autolink{ActiveFrom: &from, ActiveUntil: &until}
+	assert.False(t, l.IsActive(from.Add(-time.Second)))
+	assert.True(t, l.IsActive(from))
+	assert.True(t, l.IsActive(until.Add(-time.Second)))
+	assert.False(t, l.IsActive(until))
+
+	assert.False(t, l.Equals(autolink.Autolink{ActiveFrom: &from}))
+	sameUntil := until.In(time.FixedZone("CEST", 2*60*60))
+	assert.True(t, l.Equals(autolink.Autolink{ActiveFrom: &from, ActiveUntil: &sameUntil}))
+
+	l.Name = "Sale"
+	l.Pattern = "(sale)"
+	l.Template = "SALE"
+	assert.Equal(t, "- 1: Sale\n"+
+		"  - Pattern: `(sale)`\n"+
+		"  - Template: `SALE`\n"+
+		"  - ActiveFrom: `2024-06-01T09:00:00Z`\n"+
+		"  - ActiveUntil: `2024-06-02T09:00:00Z`\n",
+		l.ToMarkdown(1))
+}
+
+func TestAction(t *testing.T) {
+	l := autolink.Autolink{
+		Name:     "SSN",
+		Pattern:  "(?P<LastFour>\\d{4})",
+		Template: "XXXX",
+		Action:   "hide",
+	}
+	assert.EqualError(t, l.Compile(), `"hide" is not a valid action, must be "redact", "reject" or empty to replace the matches`)
+
+	l.Action = autolink.ActionRedact
+	l.AuditRedactions = true
+	require.NoError(t, l.Compile())
+	assert.Equal(t, "SSN XXXX", l.Replace("SSN 3356"))
+	assert.False(t, l.Equals(autolink.Autolink{Name: "SSN", Pattern: l.Pattern, Template: l.Template}))
+	assert.Equal(t, "- SSN\n"+
+		"  - Pattern: `(?P<LastFour>\\d{4})`\n"+
+		"  - Template: `XXXX`\n"+
+		"  - Action: `redact`\n"+
+		"  - AuditRedactions: `true`\n",
+		l.ToMarkdown(0))
+}
+
+func TestRejection(t *testing.T) {
+	l := autolink.Autolink{
+		Pattern:  "(?P<key>AKIA[0-9A-Z]{4})",
+		Template: "Posts containing AWS access keys such as $key are blocked",
+		Action:   autolink.ActionReject,
+	}
+	require.NoError(t, l.Compile())
+
+	reason, rejected := l.Rejection("the key is AKIA1234, and AKIA5678.")
+	assert.True(t, rejected)
+	assert.Equal(t, "Posts containing AWS access keys such as AKIA1234 are blocked", reason)
+
+	_, rejected = l.Rejection("the key is xAKIA1234")
+	assert.False(t, rejected)
+
+	// The escape prefix can't hide a key
+	l.HonorEscape = true
+	require.NoError(t, l.Compile())
+	reason, rejected = l.Rejection(`the key is \AKIA1234`)
+	assert.True(t, rejected)
+	assert.Equal(t, "Posts containing AWS access keys such as AKIA1234 are blocked", reason)
+}
+
+func TestUserOptional(t *testing.T) {
+	for _, tc := range []struct {
+		action   string
+		optional bool
+		valid    bool
+	}{
+		{"", true, true},
+		{"", false, true},
+		{autolink.ActionRedact, false, true},
+		{autolink.ActionRedact, true, false},
+		{autolink.ActionReject, true, false},
+	} {
+		l := autolink.Autolink{Action: tc.action, UserOptional: tc.optional}
+		assert.Equal(t, tc.valid, l.CheckUserOptional() == nil, "%q %v", tc.action, tc.optional)
+		assert.Equal(t, tc.optional && tc.valid, l.IsUserOptional(), "%q %v", tc.action, tc.optional)
+	}
+}
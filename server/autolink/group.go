@@ -0,0 +1,52 @@
+package autolink
+
+// LinkGroup is a named set of links that share settings. The links that name
+// the group in their Group field inherit its settings, unless they override
+// them.
+type LinkGroup struct {
+	Name string `json:"Name"`
+	// Disabled disables all the links of the group.
+	Disabled bool `json:"Disabled"`
+	// Scope applies to the links of the group that have no scope of their
+	// own.
+	Scope []string `json:"Scope"`
+	// ProcessBotPosts applies to the links of the group that don't set
+	// ProcessBotPosts themselves.
+	ProcessBotPosts bool `json:"ProcessBotPosts"`
+}
+
+// Effective returns the link with the settings it inherits from its group,
+// if GroupDefaults is set.
+func (l Autolink) Effective() Autolink {
+	g := l.GroupDefaults
+	if g == nil {
+		return l
+	}
+	if len(l.Scope) == 0 {
+		l.Scope = g.Scope
+	}
+	l.Disabled = l.Disabled || g.Disabled
+	if l.ProcessBotPosts == nil {
+		processBotPosts := g.ProcessBotPosts
+		l.ProcessBotPosts = &processBotPosts
+	}
+	return l
+}
+
+// inherits reports whether the effective value of a setting of the link
+// comes from its group rather than from the link itself.
+func (l Autolink) inherits(field string) bool {
+	g := l.GroupDefaults
+	if g == nil {
+		return false
+	}
+	switch field {
+	case "Disabled":
+		return !l.Disabled && g.Disabled
+	case "Scope":
+		return len(l.Scope) == 0 && len(g.Scope) > 0
+	case "ProcessBotPosts":
+		return l.ProcessBotPosts == nil && g.ProcessBotPosts
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package autolink_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestEffective(t *testing.T) {
+	group := &autolink.LinkGroup{
+		Name:            "Jira",
+		Disabled:        true,
+		Scope:           []string{"eng"},
+		ProcessBotPosts: true,
+	}
+
+	l := autolink.Autolink{Name: "MM", Group: "Jira"}
+	assert.Equal(t, l, l.Effective(), "without its defaults, the link inherits nothing")
+
+	l.GroupDefaults = group
+	e := l.Effective()
+	assert.True(t, e.Disabled)
+	assert.Equal(t, []string{"eng"}, e.Scope)
+	assert.True(t, e.ProcessesBotPosts())
+
+	l.Scope = []string{"eng/town-square"}
+	assert.Equal(t, []string{"eng/town-square"}, l.Effective().Scope, "the scope of the link overrides the group's")
+
+	no, yes := false, true
+	l.ProcessBotPosts = &no
+	assert.False(t, l.Effective().ProcessesBotPosts(), "the link overrides the group's ProcessBotPosts")
+
+	group.Disabled = false
+	group.ProcessBotPosts = false
+	l.ProcessBotPosts = &yes
+	e = l.Effective()
+	assert.False(t, e.Disabled)
+	assert.True(t, e.ProcessesBotPosts())
+}
+
+func TestGroupCompile(t *testing.T) {
+	l := autolink.Autolink{
+		Pattern:       "(?P<key>MM-\\d+)",
+		Template:      "[$key](https://jira/$key)",
+		Group:         "Jira",
+		GroupDefaults: &autolink.LinkGroup{Name: "Jira", Disabled: true},
+	}
+	require.NoError(t, l.Compile())
+	assert.Equal(t, "see MM-1", l.Replace("see MM-1"), "links of a disabled group are not applied")
+
+	l.GroupDefaults.Disabled = false
+	require.NoError(t, l.Compile())
+	assert.Equal(t, "see [MM-1](https://jira/MM-1)", l.Replace("see MM-1"))
+}
+
+func TestGroupToMarkdown(t *testing.T) {
+	l := autolink.Autolink{
+		Name:          "MM",
+		Pattern:       "(MM-\\d+)",
+		Template:      "$1",
+		Group:         "Jira",
+		GroupDefaults: &autolink.LinkGroup{Name: "Jira", Disabled: true, Scope: []string{"eng"}, ProcessBotPosts: true},
+	}
+	assert.Equal(t, "- 1: ~~MM~~ **Disabled** (from group `Jira`)\n"+
+		"  - Pattern: `(MM-\\d+)`\n"+
+		"  - Template: `$1`\n"+
+		"  - Group: `Jira`\n"+
+		"  - ProcessBotPosts: `true` (from group `Jira`)\n"+
+		"  - Scope: `[eng]` (from group `Jira`)\n",
+		l.ToMarkdown(1))
+
+	no, yes := false, true
+	l.ProcessBotPosts = &no
+	assert.Contains(t, l.ToMarkdown(1), "  - ProcessBotPosts: `false`\n", "the override of the group's value is shown")
+
+	l.Disabled = true
+	l.ProcessBotPosts = &yes
+	l.Scope = []string{"eng/town-square"}
+	assert.Equal(t, "- 1: ~~MM~~ **Disabled**\n"+
+		"  - Pattern: `(MM-\\d+)`\n"+
+		"  - Template: `$1`\n"+
+		"  - Group: `Jira`\n"+
+		"  - ProcessBotPosts: `true`\n"+
+		"  - Scope: `[eng/town-square]`\n",
+		l.ToMarkdown(1))
+}
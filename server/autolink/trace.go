@@ -0,0 +1,154 @@
+package autolink
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Match is a match of a link that Replace replaces.
+type Match struct {
+	// Start and End are the byte range of the match in the text, without
+	// the whitespace or punctuation around it that the link requires.
+	Start int    `json:"Start"`
+	End   int    `json:"End"`
+	Text  string `json:"Text"`
+	// Groups are the groups of the pattern captured by the match.
+	Groups []Group `json:"Groups,omitempty"`
+}
+
+// Group is a group of a pattern captured by a match.
+type Group struct {
+	// Index is the number of the group, $1 is the group at index 1 in the
+	// template. Name is empty for unnamed groups.
+	Index int    `json:"Index"`
+	Name  string `json:"Name,omitempty"`
+	Value string `json:"Value"`
+}
+
+// Matches returns the matches of the link in message that Replace replaces.
+func (l Autolink) Matches(message string) []Match {
+	if l.re == nil {
+		return nil
+	}
+
+	in := []byte(message)
+	if l.canReplaceAll && !(l.honorEscape && bytes.Contains(in, []byte(EscapePrefix))) {
+		matches := []Match{}
+		for _, submatch := range l.re.FindAllSubmatchIndex(in, -1) {
+			matches = append(matches, l.newMatch(in, submatch, 0))
+		}
+		return matches
+	}
+
+	// Find one at a time, the way Replace does
+	prefixIndex := l.re.SubexpIndex(nonWordPrefixGroup)
+	escape := []byte(EscapePrefix)
+	matches := []Match{}
+	offset := 0
+	for len(in) > 0 {
+		submatch := l.re.FindSubmatchIndex(in)
+		if submatch == nil {
+			break
+		}
+
+		escaped := false
+		if l.honorEscape {
+			start := submatch[0]
+			if prefixIndex > 0 && submatch[2*prefixIndex+1] >= 0 {
+				start = submatch[2*prefixIndex+1]
+			}
+			escaped = start >= len(escape) && bytes.Equal(in[start-len(escape):start], escape)
+		}
+		if !escaped {
+			matches = append(matches, l.newMatch(in, submatch, offset))
+		}
+
+		offset += submatch[1]
+		in = in[submatch[1]:]
+	}
+	return matches
+}
+
+// newMatch builds the match of submatch in text, which starts at offset in
+// the message.
+func (l Autolink) newMatch(text []byte, submatch []int, offset int) Match {
+	start, end := submatch[0], submatch[1]
+	if i := l.re.SubexpIndex(nonWordPrefixGroup); i > 0 && submatch[2*i+1] >= 0 {
+		start = submatch[2*i+1]
+	}
+	if i := l.re.SubexpIndex(nonWordSuffixGroup); i > 0 && submatch[2*i] >= 0 {
+		end = submatch[2*i]
+	}
+
+	m := Match{
+		Start: start + offset,
+		End:   end + offset,
+		Text:  string(text[start:end]),
+	}
+	for i, name := range l.re.SubexpNames() {
+		if i == 0 || isWrapperGroup(l.re, i) || submatch[2*i] < 0 {
+			continue
+		}
+		m.Groups = append(m.Groups, Group{
+			Index: i,
+			Name:  name,
+			Value: string(text[submatch[2*i]:submatch[2*i+1]]),
+		})
+	}
+	return m
+}
+
+// isWrapperGroup returns true if the group at index i was added by Compile
+// around the pattern of the link, rather than being part of it.
+func isWrapperGroup(re *regexp.Regexp, i int) bool {
+	prefix := re.SubexpIndex(nonWordPrefixGroup)
+	return (prefix > 0 && (i == prefix || i == prefix+1)) || i == re.SubexpIndex(nonWordSuffixGroup)
+}
+
+// Trace describes how links were applied to a message, step by step.
+type Trace struct {
+	Message string `json:"Message"`
+	Result  string `json:"Result"`
+	// Skipped are the enabled links that were not applied to the message
+	// at all, e.g. because of their scope.
+	Skipped []TraceSkip `json:"Skipped,omitempty"`
+	// Segments are the pieces of text the links changed. Code, links and
+	// the rest of the markdown are not processed.
+	Segments []TraceSegment `json:"Segments,omitempty"`
+	// Rejection is the reject link that rejected the message, if any. The
+	// other links are not applied then.
+	Rejection *TraceRejection `json:"Rejection,omitempty"`
+}
+
+// TraceRejection is a reject link that matched the message, and the reason
+// given to its author.
+type TraceRejection struct {
+	Link   string `json:"Link"`
+	Reason string `json:"Reason"`
+}
+
+// TraceSkip is a link that was not applied, and why.
+type TraceSkip struct {
+	Link   string `json:"Link"`
+	Reason string `json:"Reason"`
+}
+
+// TraceSegment is a piece of text of the message, and the links applied to
+// it in order.
+type TraceSegment struct {
+	// Start and End are the byte range of the text in the message.
+	Start int         `json:"Start"`
+	End   int         `json:"End"`
+	Text  string      `json:"Text"`
+	Steps []TraceStep `json:"Steps"`
+}
+
+// TraceStep is a link that matched a segment.
+type TraceStep struct {
+	Link string `json:"Link"`
+	// Matches are in the text of the segment as the previous links left it.
+	Matches []Match `json:"Matches"`
+	// Result is the text after the link was applied, unless it was skipped.
+	Result  string `json:"Result,omitempty"`
+	Skipped string `json:"Skipped,omitempty"`
+}
@@ -0,0 +1,90 @@
+package autolink_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		link     autolink.Autolink
+		message  string
+		expected []autolink.Match
+	}{
+		{
+			"named groups, without the surrounding whitespace",
+			autolink.Autolink{
+				Pattern:  "(?P<project>MM)-(?P<id>\\d+)",
+				Template: "[$project-$id](https://jira/$project-$id)",
+			},
+			"See MM-12, MM-3.",
+			[]autolink.Match{{
+				Start: 4, End: 9, Text: "MM-12",
+				Groups: []autolink.Group{{Index: 3, Name: "project", Value: "MM"}, {Index: 4, Name: "id", Value: "12"}},
+			}, {
+				Start: 11, End: 15, Text: "MM-3",
+				Groups: []autolink.Group{{Index: 3, Name: "project", Value: "MM"}, {Index: 4, Name: "id", Value: "3"}},
+			}},
+		},
+		{
+			"numbered groups with word boundaries",
+			autolink.Autolink{
+				Pattern:   "(\\d+)-(\\d+)",
+				Template:  "range",
+				WordMatch: true,
+			},
+			"1-2 and 30-40",
+			[]autolink.Match{{
+				Start: 0, End: 3, Text: "1-2",
+				Groups: []autolink.Group{{Index: 1, Value: "1"}, {Index: 2, Value: "2"}},
+			}, {
+				Start: 8, End: 13, Text: "30-40",
+				Groups: []autolink.Group{{Index: 1, Value: "30"}, {Index: 2, Value: "40"}},
+			}},
+		},
+		{
+			"no prefix nor suffix",
+			autolink.Autolink{
+				Pattern:              "MM-\\d+",
+				Template:             "issue",
+				DisableNonWordPrefix: true,
+				DisableNonWordSuffix: true,
+			},
+			"xMM-1x",
+			[]autolink.Match{{Start: 1, End: 5, Text: "MM-1"}},
+		},
+		{
+			"escaped matches are not replaced",
+			autolink.Autolink{
+				Pattern:     "(MM-\\d+)",
+				Template:    "issue",
+				HonorEscape: true,
+			},
+			"\\MM-1 MM-2",
+			[]autolink.Match{{Start: 6, End: 10, Text: "MM-2", Groups: []autolink.Group{{Index: 3, Value: "MM-2"}}}},
+		},
+		{
+			"no match",
+			autolink.Autolink{
+				Pattern:  "(MM-\\d+)",
+				Template: "issue",
+			},
+			"nothing here",
+			[]autolink.Match{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.link.Compile())
+			assert.Equal(t, tc.expected, tc.link.Matches(tc.message))
+		})
+	}
+
+	disabled := autolink.Autolink{Pattern: "(x)", Template: "y", Disabled: true}
+	require.NoError(t, disabled.Compile())
+	assert.Nil(t, disabled.Matches("x"))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
 )
@@ -64,3 +65,61 @@ func (c *Client) Add(links ...autolink.Autolink) error {
 
 	return nil
 }
+
+// Delete deletes the named links. Only the links that were added by the
+// calling plugin can be deleted.
+func (c *Client) Delete(names ...string) error {
+	for _, name := range names {
+		req, err := http.NewRequest("DELETE", "/"+autolinkPluginID+"/api/v1/link?name="+url.QueryEscape(name), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("unable to delete autolink. Error: %v, %v", resp.StatusCode, string(respBody))
+		}
+	}
+
+	return nil
+}
+
+// Test applies all the enabled links to message, as if it was posted in
+// channelID, and returns the trace of each step.
+func (c *Client) Test(channelID, message string) (*autolink.Trace, error) {
+	body, err := json.Marshal(struct {
+		Message   string `json:"message"`
+		ChannelID string `json:"channel_id"`
+	}{message, channelID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "/"+autolinkPluginID+"/api/v1/test", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unable to test the autolinks. Error: %v, %v", resp.StatusCode, string(respBody))
+	}
+
+	var trace autolink.Trace
+	if err = json.NewDecoder(resp.Body).Decode(&trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
@@ -1,7 +1,9 @@
 package autolinkclient
 
 import (
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
@@ -53,3 +55,31 @@ func TestAddAutolinksErr(t *testing.T) {
 	err := client.Add(autolink.Autolink{})
 	require.Error(t, err)
 }
+
+func TestDeleteAutolinks(t *testing.T) {
+	mockPluginAPI := &plugintest.API{}
+
+	mockPluginAPI.On("PluginHTTP", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Method == "DELETE" && req.URL.Query().Get("name") == "my link"
+	})).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody})
+
+	client := NewClientPlugin(mockPluginAPI)
+	err := client.Delete("my link")
+	require.Nil(t, err)
+}
+
+func TestTestAutolinks(t *testing.T) {
+	mockPluginAPI := &plugintest.API{}
+
+	mockPluginAPI.On("PluginHTTP", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Method == "POST" && req.URL.Path == "/mattermost-autolink/api/v1/test"
+	})).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"Message": "MM-1", "Result": "[MM-1](https://jira/MM-1)"}`)),
+	})
+
+	client := NewClientPlugin(mockPluginAPI)
+	trace, err := client.Test("channelId", "MM-1")
+	require.Nil(t, err)
+	require.Equal(t, "[MM-1](https://jira/MM-1)", trace.Result)
+}
@@ -0,0 +1,198 @@
+package autolinkplugin
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// commandArg is an argument of a slash command, with the byte range of its
+// raw text in the command.
+type commandArg struct {
+	value      string
+	start, end int
+}
+
+// splitCommand splits a slash command into its arguments, the way a shell
+// would. Arguments are separated by whitespace, which can be kept in an
+// argument by quoting it:
+//   - text in single quotes is taken literally,
+//   - in double quotes, a backslash escapes a double quote or a backslash,
+//   - elsewhere, a backslash escapes whitespace, a quote or a backslash.
+//
+// Other backslashes are kept as they are, so that patterns such as \d don't
+// need to be escaped.
+func splitCommand(command string) ([]commandArg, error) {
+	return splitCommandN(command, -1)
+}
+
+// splitCommandN splits the first n arguments of a command like splitCommand,
+// or all of them if n is negative. The rest of the command is left as it is.
+func splitCommandN(command string, n int) ([]commandArg, error) {
+	args := []commandArg{}
+	var current *commandArg
+	var value strings.Builder
+
+	start := func(i int) {
+		if current == nil {
+			current = &commandArg{start: i}
+			value.Reset()
+		}
+	}
+
+	for i := 0; i < len(command) && len(args) != n; {
+		r, size := utf8.DecodeRuneInString(command[i:])
+		switch {
+		case unicode.IsSpace(r):
+			if current != nil {
+				current.value = value.String()
+				current.end = i
+				args = append(args, *current)
+				current = nil
+			}
+			i += size
+
+		case r == '\'':
+			start(i)
+			end := strings.IndexRune(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated single quote in %q", command[i:])
+			}
+			value.WriteString(command[i+1 : i+1+end])
+			i += end + 2
+
+		case r == '"':
+			start(i)
+			i++
+			closed := false
+			for i < len(command) {
+				if command[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				if command[i] == '\\' && i+1 < len(command) && (command[i+1] == '"' || command[i+1] == '\\') {
+					i++
+				}
+				value.WriteByte(command[i])
+				i++
+			}
+			if !closed {
+				return nil, errors.Errorf("unterminated double quote in %q", command[current.start:])
+			}
+
+		case r == '\\':
+			start(i)
+			next, nextSize := utf8.DecodeRuneInString(command[i+1:])
+			if nextSize > 0 && (unicode.IsSpace(next) || next == '\'' || next == '"' || next == '\\') {
+				value.WriteRune(next)
+				i += 1 + nextSize
+			} else {
+				value.WriteByte('\\')
+				i++
+			}
+
+		default:
+			start(i)
+			value.WriteRune(r)
+			i += size
+		}
+	}
+	if current != nil {
+		current.value = value.String()
+		current.end = len(command)
+		args = append(args, *current)
+	}
+	return args, nil
+}
+
+// argValues returns the values of args.
+func argValues(args []commandArg) []string {
+	values := []string{}
+	for _, arg := range args {
+		values = append(values, arg.value)
+	}
+	return values
+}
+
+// rawValueCommands are the commands that take a value, such as a template
+// or a sample text, and the number of their arguments before it. Only these
+// arguments are split like splitCommand does, the value is the rest of the
+// command as it is, so that quotes and backslashes are kept.
+var rawValueCommands = map[string]int{
+	"list":    1,
+	"set":     2,
+	"test":    1,
+	"var/set": 1,
+}
+
+// parseCommand splits a slash command into its arguments. The value of the
+// rawValueCommands is split on whitespace only, each word being an argument.
+func parseCommand(command string) ([]commandArg, error) {
+	// "/autolink" and a subcommand of up to two words
+	head, err := splitCommandN(command, 3)
+	if err != nil {
+		return nil, err
+	}
+	path := argValues(head)
+	for len(path) > 1 {
+		if n, ok := rawValueCommands[strings.Join(path[1:], "/")]; ok {
+			return splitRawValue(command, len(path)+n)
+		}
+		path = path[:len(path)-1]
+	}
+	return splitCommand(command)
+}
+
+// splitRawValue splits the first n arguments of command like splitCommand,
+// and the rest on whitespace.
+func splitRawValue(command string, n int) ([]commandArg, error) {
+	args, err := splitCommandN(command, n)
+	if err != nil || len(args) < n {
+		return args, err
+	}
+	offset := args[n-1].end
+	rest := command[offset:]
+	for {
+		start := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsSpace(r) })
+		if start < 0 {
+			return args, nil
+		}
+		end := strings.IndexFunc(rest[start:], unicode.IsSpace)
+		if end < 0 {
+			end = len(rest) - start
+		}
+		args = append(args, commandArg{value: rest[start : start+end], start: offset + start, end: offset + start + end})
+		offset += start + end
+		rest = rest[start+end:]
+	}
+}
+
+// restOfCommand returns the value made of the last n arguments of the
+// command, as it is in the command. It is used for the values that may
+// contain spaces, such as a template or a sample text. A pair of quotes
+// alone is an empty value, so that a field can be cleared.
+func restOfCommand(command string, n int) string {
+	args, err := parseCommand(command)
+	if err != nil || n <= 0 || n > len(args) {
+		return ""
+	}
+	rest := command[args[len(args)-n].start:args[len(args)-1].end]
+	if rest == `""` || rest == "''" {
+		return ""
+	}
+	return rest
+}
+
+// quoteArg quotes s if needed, so that it is a single argument of a command.
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, "'\"\\") && strings.IndexFunc(s, unicode.IsSpace) < 0 {
+		return s
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,171 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		command  string
+		expected []string
+		err      string
+	}{
+		{"empty", "", []string{}, ""},
+		{"spaces only", "  \t ", []string{}, ""},
+		{"words", "/autolink list", []string{"/autolink", "list"}, ""},
+		{"repeated whitespace", " /autolink \t list\n Jira ", []string{"/autolink", "list", "Jira"}, ""},
+		{"double quotes", `set "My link" Template`, []string{"set", "My link", "Template"}, ""},
+		{"single quotes", `set 'My link' Template`, []string{"set", "My link", "Template"}, ""},
+		{"empty quotes", `a "" ''`, []string{"a", "", ""}, ""},
+		{"adjacent quotes", `a"b c"'d e'f`, []string{"ab cd ef"}, ""},
+		{"single quotes are literal", `'a\"b' '\\'`, []string{`a\"b`, `\\`}, ""},
+		{"escaped double quote", `"say \"hi\""`, []string{`say "hi"`}, ""},
+		{"escaped backslash in double quotes", `"a\\b"`, []string{`a\b`}, ""},
+		{"other backslash in double quotes", `"\d+"`, []string{`\d+`}, ""},
+		{"escaped space", `My\ link x`, []string{"My link", "x"}, ""},
+		{"escaped quotes", `it\'s \"quoted\"`, []string{"it's", `"quoted"`}, ""},
+		{"escaped backslash", `a\\ b`, []string{`a\`, "b"}, ""},
+		{"regexp backslashes are kept", `(?P<key>MM-\d+)\b`, []string{`(?P<key>MM-\d+)\b`}, ""},
+		{"trailing backslash", `a\`, []string{`a\`}, ""},
+		{"unquoted apostrophe in a name", `Jira's`, nil, "unterminated single quote"},
+		{"unterminated double quote", `set "My link`, nil, "unterminated double quote"},
+		{"unterminated escaped quote", `"a\"`, nil, "unterminated double quote"},
+		{"unicode", `"héllo wörld" ✓`, []string{"héllo wörld", "✓"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := splitCommand(tc.command)
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, argValues(args))
+		})
+	}
+}
+
+func TestRestOfCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		command  string
+		n        int
+		expected string
+	}{
+		{"last word", "/autolink set Jira Template x", 1, "x"},
+		{"whitespace is kept", "/autolink test Jira a  b\tc  ", 3, "a  b\tc"},
+		{"quotes are kept", `/autolink set "Jira" Template [x](url "title")  '($key)'`, 3, `[x](url "title")  '($key)'`},
+		{"backslashes are kept", `/autolink set Jira Pattern "[^"]*"\\d`, 1, `"[^"]*"\\d`},
+		{"apostrophe", `/autolink test Jira it's MM-1`, 2, "it's MM-1"},
+		{"empty value", `/autolink set Jira Template ""`, 1, ""},
+		{"name in the value", "/autolink set Jira Template Jira Jira", 2, "Jira Jira"},
+		{"none", "/autolink set Jira Template", 0, ""},
+		{"too many", "/autolink set", 3, ""},
+		{"invalid", `/autolink set "Jira`, 1, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, restOfCommand(tc.command, tc.n))
+		})
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	for _, tc := range []struct {
+		arg      string
+		expected string
+	}{
+		{"Jira", "Jira"},
+		{"", "''"},
+		{"My link", "'My link'"},
+		{`say "hi"`, `'say "hi"'`},
+		{`Jira's link`, `"Jira's link"`},
+		{`it's a \ "test"`, `"it's a \\ \"test\""`},
+	} {
+		t.Run(tc.arg, func(t *testing.T) {
+			quoted := quoteArg(tc.arg)
+			assert.Equal(t, tc.expected, quoted)
+
+			args, err := splitCommand(quoted)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.arg}, argValues(args))
+		})
+	}
+}
+
+func TestQuotedCommands(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(?P<key>MM-\\d+)",
+			Template: "[$key](https://jira/$key)",
+		}, {
+			Name:     "My link",
+			Pattern:  "(link)",
+			Template: "LINK",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:  "adminId",
+			Command: command,
+		})
+		return resp.Text
+	}
+
+	t.Run("name in the value", func(t *testing.T) {
+		run("/autolink set Jira Template Jira [$key](https://jira/$key)")
+		assert.Equal(t, "Jira [$key](https://jira/$key)", p.getConfig().Links[0].Template)
+	})
+
+	t.Run("regexp backslashes", func(t *testing.T) {
+		run(`/autolink set Jira Pattern (?P<key>MM-\d+)\b`)
+		assert.Equal(t, `(?P<key>MM-\d+)\b`, p.getConfig().Links[0].Pattern)
+	})
+
+	t.Run("quoted name", func(t *testing.T) {
+		run(`/autolink set "My link" Template [a](url "quoted")  link`)
+		assert.Equal(t, `[a](url "quoted")  link`, p.getConfig().Links[1].Template)
+
+		run(`/autolink disable My\ link`)
+		assert.True(t, p.getConfig().Links[1].Disabled)
+	})
+
+	t.Run("values are taken as they are", func(t *testing.T) {
+		text := run(`/autolink test Jira it's MM-1`)
+		assert.Contains(t, text, "- Original: `it's MM-1`")
+
+		run(`/autolink set Jira Pattern (?P<key>"[^"]*")\\`)
+		assert.Equal(t, `(?P<key>"[^"]*")\\`, p.getConfig().Links[0].Pattern)
+	})
+
+	t.Run("unterminated quote", func(t *testing.T) {
+		text := run(`/autolink set "My link Template x`)
+		assert.Contains(t, text, "unterminated double quote")
+	})
+}
@@ -0,0 +1,330 @@
+package autolinkplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const (
+	// auditKeyPrefix starts the KV keys of the audit events, one per event,
+	// followed by the time of the event so that the keys sort in time order.
+	auditKeyPrefix = "audit_"
+
+	// auditRetention is how long the events are kept in the KV store.
+	auditRetention = 90 * 24 * time.Hour
+
+	// auditPageSize is the number of keys listed at a time to find the
+	// audit events.
+	auditPageSize = 100
+
+	defaultAuditListSize = 20
+)
+
+// Sources of audit events.
+const (
+	auditSourceCommand = "slash_command"
+	auditSourceAPI     = "rest_api"
+	auditSourceDialog  = "interactive_dialog"
+	auditSourceConfig  = "config_file"
+	auditSourcePlugin  = "autolink_plugin"
+)
+
+// Results of audit events.
+const (
+	auditResultSuccess = "success"
+	auditResultDenied  = "denied"
+)
+
+// AuditEvent records an administrative action or an authorization decision,
+// or a post redacted by a link.
+type AuditEvent struct {
+	Timestamp int64         `json:"timestamp"`
+	Source    string        `json:"source"`
+	UserID    string        `json:"user_id,omitempty"`
+	PluginID  string        `json:"plugin_id,omitempty"`
+	Action    string        `json:"action"`
+	Link      string        `json:"link,omitempty"`
+	ChannelID string        `json:"channel_id,omitempty"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+	Result    string        `json:"result"`
+}
+
+// FieldChange is the change of a single field of a link.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// diffLinks returns the changes of the stored fields between two versions of
+// a link. A nil before or after stands for a link that was added or removed.
+func diffLinks(before, after *autolink.Autolink) []FieldChange {
+	var b, a reflect.Value
+	if before != nil {
+		b = reflect.ValueOf(*before)
+	}
+	if after != nil {
+		a = reflect.ValueOf(*after)
+	}
+
+	changes := []FieldChange{}
+	t := reflect.TypeOf(autolink.Autolink{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		oldValue, newValue := "", ""
+		if b.IsValid() {
+			oldValue = formatFieldValue(b.Field(i))
+		}
+		if a.IsValid() {
+			newValue = formatFieldValue(a.Field(i))
+		}
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{Field: field.Name, Old: oldValue, New: newValue})
+		}
+	}
+
+	return changes
+}
+
+func formatFieldValue(v reflect.Value) string {
+	if v.IsZero() {
+		return ""
+	}
+	if t, ok := v.Interface().(*time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// auditLinkChange records a change of a link, made or attempted by a user or
+// a plugin.
+func (p *Plugin) auditLinkChange(source, userID, pluginID, action string, before, after *autolink.Autolink, result string) {
+	name := ""
+	switch {
+	case after != nil:
+		name = after.DisplayName()
+	case before != nil:
+		name = before.DisplayName()
+	}
+
+	p.audit(AuditEvent{
+		Source:   source,
+		UserID:   userID,
+		PluginID: pluginID,
+		Action:   action,
+		Link:     name,
+		Changes:  diffLinks(before, after),
+		Result:   result,
+	})
+
+	// The plugin tells the owners and the notification channel itself why it
+	// changed a link
+	if result == auditResultSuccess && source != auditSourcePlugin {
+		p.notifyOwnerOfChange(source, userID, pluginID, action, before, after)
+		p.notifyChannel("Link " + p.describeChange(source, userID, pluginID, action, before, after))
+	}
+}
+
+// AuditLinkChange records a change of a link made or attempted through the
+// REST API.
+func (p *Plugin) AuditLinkChange(userID, pluginID, action string, before, after *autolink.Autolink, allowed bool) {
+	result := auditResultSuccess
+	if !allowed {
+		result = auditResultDenied
+	}
+	p.auditLinkChange(auditSourceAPI, userID, pluginID, action, before, after, result)
+}
+
+// auditConfigChange records the differences between the links of two
+// versions of the configuration, e.g. after config.json was edited.
+func (p *Plugin) auditConfigChange(oldLinks, newLinks []autolink.Autolink) {
+	oldByName := map[string]autolink.Autolink{}
+	for _, l := range oldLinks {
+		oldByName[l.DisplayName()] = l
+	}
+
+	for _, l := range newLinks {
+		after := l
+		before, ok := oldByName[l.DisplayName()]
+		delete(oldByName, l.DisplayName())
+		switch {
+		case !ok:
+			p.auditLinkChange(auditSourceConfig, "", "", "add", nil, &after, auditResultSuccess)
+		case !before.Equals(after):
+			p.auditLinkChange(auditSourceConfig, "", "", "set", &before, &after, auditResultSuccess)
+		}
+	}
+
+	for _, l := range oldLinks {
+		if _, ok := oldByName[l.DisplayName()]; ok {
+			before := l
+			p.auditLinkChange(auditSourceConfig, "", "", "delete", &before, nil, auditResultSuccess)
+		}
+	}
+}
+
+// audit writes event to the server log, and saves it in the KV store.
+// Failures are logged, they never fail the audited action.
+func (p *Plugin) audit(event AuditEvent) {
+	if event.Timestamp == 0 {
+		event.Timestamp = model.GetMillis()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		p.API.LogError("Failed to encode audit event", "error", err.Error())
+		return
+	}
+	p.API.LogInfo("Autolink audit event", "event", string(data))
+
+	if err = p.saveAuditEvent(event.Timestamp, data); err != nil {
+		p.API.LogError("Failed to save audit event", "error", err.Error())
+	}
+}
+
+// auditKey returns the KV key of an event saved at timestamp. Events saved
+// in the same millisecond are ordered by seq, and made unique across
+// servers by a random ID.
+func auditKey(timestamp int64, seq uint64) string {
+	return fmt.Sprintf("%s%013d_%09d_%s", auditKeyPrefix, timestamp, seq%1000000000, model.NewId())
+}
+
+// saveAuditEvent stores an encoded event under its own key, so that saving
+// it is a single write whatever the size of the audit log. The KV store
+// deletes it after auditRetention.
+func (p *Plugin) saveAuditEvent(timestamp int64, data []byte) error {
+	p.auditLock.Lock()
+	p.auditSeq++
+	key := auditKey(timestamp, p.auditSeq)
+	p.auditLock.Unlock()
+
+	_, appErr := p.API.KVSetWithOptions(key, data, model.PluginKVSetOptions{
+		ExpireInSeconds: int64(auditRetention / time.Second),
+	})
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to save the audit event")
+	}
+	return nil
+}
+
+// getAuditEvents returns the events saved since the given time, or all of
+// them if since is zero, oldest first. If limit is positive, only the limit most recent ones are loaded.
+func (p *Plugin) getAuditEvents(since time.Time, limit int) ([]AuditEvent, error) {
+	first := auditKeyPrefix
+	if !since.IsZero() {
+		first = fmt.Sprintf("%s%013d", auditKeyPrefix, since.UnixMilli())
+	}
+	keys := []string{}
+	for page := 0; ; page++ {
+		pageKeys, appErr := p.API.KVList(page, auditPageSize)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to list the audit events")
+		}
+		for _, key := range pageKeys {
+			if strings.HasPrefix(key, auditKeyPrefix) && key >= first {
+				keys = append(keys, key)
+			}
+		}
+		if len(pageKeys) < auditPageSize {
+			break
+		}
+	}
+
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[len(keys)-limit:]
+	}
+
+	events := []AuditEvent{}
+	for _, key := range keys {
+		data, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to load the audit event")
+		}
+		// The event expired since it was listed
+		if data == nil {
+			continue
+		}
+		var event AuditEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, errors.Wrap(err, "failed to decode the audit event")
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func executeAudit(p *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) > 1 {
+		return responsef(helpText)
+	}
+
+	n := defaultAuditListSize
+	if len(args) == 1 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 {
+			return responsef("%q is not a valid number of events", args[0])
+		}
+		n = parsed
+	}
+
+	events, err := p.getAuditEvents(time.Time{}, n)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	if len(events) == 0 {
+		return responsef("The audit log is empty")
+	}
+
+	text := ""
+	for i := len(events) - 1; i >= 0; i-- {
+		text += events[i].ToMarkdown()
+	}
+	return responsef(text)
+}
+
+// ToMarkdown prints an AuditEvent as a markdown list element
+func (e AuditEvent) ToMarkdown() string {
+	actor := []string{}
+	if e.UserID != "" {
+		actor = append(actor, fmt.Sprintf("user `%s`", e.UserID))
+	}
+	if e.PluginID != "" {
+		actor = append(actor, fmt.Sprintf("plugin `%s`", e.PluginID))
+	}
+	if len(actor) == 0 {
+		actor = append(actor, "unknown")
+	}
+
+	text := fmt.Sprintf("- %s: **%s**", time.UnixMilli(e.Timestamp).UTC().Format(time.RFC3339), e.Action)
+	if e.Link != "" {
+		text += fmt.Sprintf(" `%s`", e.Link)
+	}
+	if e.Result != auditResultSuccess {
+		text += fmt.Sprintf(" **%s**", e.Result)
+	}
+	if e.ChannelID != "" {
+		text += fmt.Sprintf(" in channel `%s`", e.ChannelID)
+	}
+	text += fmt.Sprintf(" by %s via %s\n", strings.Join(actor, ", "), e.Source)
+
+	for _, c := range e.Changes {
+		text += fmt.Sprintf("  - %s: `%s` → `%s`\n", c.Field, c.Old, c.New)
+	}
+	return text
+}
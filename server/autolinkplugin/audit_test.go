@@ -0,0 +1,258 @@
+package autolinkplugin
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// mockAuditLog backs the audit events with an in-memory KV store, and returns
+// a function listing the events recorded so far.
+func mockAuditLog(api *plugintest.API) func() []AuditEvent {
+	// Some events are saved in the background
+	var lock sync.Mutex
+	stored := map[string][]byte{}
+	isAuditKey := mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, auditKeyPrefix) })
+	sortedKeys := func() []string {
+		keys := []string{}
+		for key := range stored {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	api.On("LogInfo", "Autolink audit event", "event", mock.AnythingOfType("string")).Return()
+	api.On("KVSetWithOptions", isAuditKey, mock.Anything, mock.AnythingOfType("model.PluginKVSetOptions")).Return(func(key string, value []byte, _ model.PluginKVSetOptions) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		stored[key] = value
+		return true
+	}, nil)
+	api.On("KVList", mock.AnythingOfType("int"), auditPageSize).Return(func(page, perPage int) []string {
+		lock.Lock()
+		defer lock.Unlock()
+		keys := sortedKeys()
+		if page*perPage >= len(keys) {
+			return []string{}
+		}
+		keys = keys[page*perPage:]
+		if len(keys) > perPage {
+			keys = keys[:perPage]
+		}
+		return keys
+	}, nil)
+	api.On("KVGet", isAuditKey).Return(func(key string) []byte {
+		lock.Lock()
+		defer lock.Unlock()
+		return stored[key]
+	}, nil)
+
+	return func() []AuditEvent {
+		lock.Lock()
+		defer lock.Unlock()
+		events := []AuditEvent{}
+		for _, key := range sortedKeys() {
+			var event AuditEvent
+			_ = json.Unmarshal(stored[key], &event)
+			events = append(events, event)
+		}
+		return events
+	}
+}
+
+func TestDiffLinks(t *testing.T) {
+	before := autolink.Autolink{
+		Name:     "Visa",
+		Pattern:  "(4\\d{3})",
+		Template: "VISA",
+	}
+	after := before
+	after.Template = "XXXX"
+	after.Disabled = true
+	after.Scope = []string{"eng"}
+
+	assert.Equal(t, []FieldChange{
+		{Field: "Disabled", Old: "", New: "true"},
+		{Field: "Template", Old: "VISA", New: "XXXX"},
+		{Field: "Scope", Old: "", New: "[eng]"},
+	}, diffLinks(&before, &after))
+
+	assert.Equal(t, []FieldChange{
+		{Field: "Name", Old: "Visa", New: ""},
+		{Field: "Pattern", Old: "(4\\d{3})", New: ""},
+		{Field: "Template", Old: "VISA", New: ""},
+	}, diffLinks(&before, nil))
+
+	assert.Empty(t, diffLinks(&before, &before))
+}
+
+func TestAuditCommands(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Editors:            "editorId",
+		Links: []autolink.Autolink{{
+			Name:     "Visa",
+			Pattern:  "(?P<LastFour>4\\d{3})",
+			Template: "XXXX-$LastFour",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("GetUser", "editorId").Return(&model.User{Id: "editorId", Roles: "system_user"}, nil)
+	api.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	api.On("HasPermissionToTeam", mock.AnythingOfType("string"), mock.AnythingOfType("string"), model.PermissionManageTeam).Return(false)
+	api.On("HasPermissionToChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), model.PermissionManageChannelRoles).Return(false)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	events := mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(userID, command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:  userID,
+			Command: command,
+		})
+		return resp.Text
+	}
+
+	run("editorId", "/autolink set Visa Template VISA-$LastFour")
+	run("editorId", "/autolink delete Visa")
+	run("userId", "/autolink disable Visa")
+	run("adminId", "/autolink disable Visa")
+
+	logged := events()
+	require.Len(t, logged, 4)
+
+	assert.Equal(t, auditSourceCommand, logged[0].Source)
+	assert.Equal(t, "editorId", logged[0].UserID)
+	assert.Equal(t, "set", logged[0].Action)
+	assert.Equal(t, "Visa", logged[0].Link)
+	assert.Equal(t, auditResultSuccess, logged[0].Result)
+	assert.Equal(t, []FieldChange{{Field: "Template", Old: "XXXX-$LastFour", New: "VISA-$LastFour"}}, logged[0].Changes)
+
+	assert.Equal(t, "delete", logged[1].Action)
+	assert.Equal(t, auditResultDenied, logged[1].Result)
+
+	assert.Equal(t, "userId", logged[2].UserID)
+	assert.Equal(t, "disable", logged[2].Action)
+	assert.Equal(t, auditResultDenied, logged[2].Result)
+
+	assert.Equal(t, "adminId", logged[3].UserID)
+	assert.Equal(t, auditResultSuccess, logged[3].Result)
+	assert.Equal(t, []FieldChange{{Field: "Disabled", Old: "", New: "true"}}, logged[3].Changes)
+
+	assert.Contains(t, run("editorId", "/autolink audit"), "You do not have permission")
+
+	out := run("adminId", "/autolink audit 3")
+	assert.Contains(t, out, "**audit** **denied** by user `editorId` via slash_command")
+	assert.Contains(t, out, "**disable** `Visa` by user `adminId` via slash_command")
+	assert.Contains(t, out, "**disable** **denied** by user `userId`")
+	assert.NotContains(t, out, "**delete**")
+}
+
+func TestAuditConfigChange(t *testing.T) {
+	conf := Config{
+		Links: []autolink.Autolink{{
+			Name:     "Visa",
+			Pattern:  "(4\\d{3})",
+			Template: "VISA",
+		}, {
+			Name:     "Removed",
+			Pattern:  "(removed)",
+			Template: "REMOVED",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		data, _ := json.Marshal(conf)
+		return json.Unmarshal(data, dest)
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	events := mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+	assert.Empty(t, events())
+
+	conf.Links = []autolink.Autolink{{
+		Name:     "Visa",
+		Pattern:  "(4\\d{3})",
+		Template: "XXXX",
+	}, {
+		Name:     "Added",
+		Pattern:  "(added)",
+		Template: "ADDED",
+	}}
+	require.NoError(t, p.OnConfigurationChange())
+
+	logged := events()
+	require.Len(t, logged, 3)
+	for _, e := range logged {
+		assert.Equal(t, auditSourceConfig, e.Source)
+		assert.Equal(t, auditResultSuccess, e.Result)
+	}
+	assert.Equal(t, "set", logged[0].Action)
+	assert.Equal(t, "Visa", logged[0].Link)
+	assert.Equal(t, []FieldChange{{Field: "Template", Old: "VISA", New: "XXXX"}}, logged[0].Changes)
+	assert.Equal(t, "add", logged[1].Action)
+	assert.Equal(t, "Added", logged[1].Link)
+	assert.Equal(t, "delete", logged[2].Action)
+	assert.Equal(t, "Removed", logged[2].Link)
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	api := &plugintest.API{}
+	events := mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	// More events than a page of keys, some in the same millisecond
+	for i := 0; i < auditPageSize+5; i++ {
+		p.audit(AuditEvent{Timestamp: now.Add(time.Duration(i/2) * time.Minute).UnixMilli(), Action: strconv.Itoa(i), Result: auditResultSuccess})
+	}
+	require.Len(t, events(), auditPageSize+5)
+	api.AssertCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, model.PluginKVSetOptions{ExpireInSeconds: 90 * 24 * 60 * 60})
+	api.AssertNotCalled(t, "KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything)
+
+	all, err := p.getAuditEvents(time.Time{}, 0)
+	require.NoError(t, err)
+	require.Len(t, all, auditPageSize+5)
+	for i, e := range all {
+		assert.Equal(t, strconv.Itoa(i), e.Action, "the events are in the order they were saved")
+	}
+
+	latest, err := p.getAuditEvents(time.Time{}, 3)
+	require.NoError(t, err)
+	require.Len(t, latest, 3)
+	assert.Equal(t, strconv.Itoa(auditPageSize+2), latest[0].Action)
+
+	recent, err := p.getAuditEvents(now.Add(50*time.Minute), 0)
+	require.NoError(t, err)
+	require.Len(t, recent, auditPageSize+5-100)
+	assert.Equal(t, "100", recent[0].Action)
+}
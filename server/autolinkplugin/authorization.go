@@ -0,0 +1,146 @@
+package autolinkplugin
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// CanManageLink reports whether userID may create, change or delete l.
+// Plugin admins and editors may manage any link, team and channel admins
+// only the links whose Scope is limited to the teams and channels they
+// administer.
+func (p *Plugin) CanManageLink(userID string, l autolink.Autolink) (bool, error) {
+	level, err := p.getPermissionLevel(userID)
+	if err != nil || level >= permissionEdit {
+		return level >= permissionEdit, err
+	}
+
+	return p.canManageScope(userID, l.Scope)
+}
+
+// CanDeleteLink reports whether userID may delete l. Editors can't delete
+// links, plugin admins may delete any link, team and channel admins only the
+// links whose Scope is limited to the teams and channels they administer.
+func (p *Plugin) CanDeleteLink(userID string, l autolink.Autolink) (bool, error) {
+	level, err := p.getPermissionLevel(userID)
+	if err != nil || level >= permissionEdit {
+		return level >= permissionOwn, err
+	}
+
+	return p.canManageScope(userID, l.Scope)
+}
+
+// IsAuthorizedPlugin reports whether the plugin pluginID may use the REST API.
+// Only the plugins in AllowedPlugins may, unless AllowAnyPlugin is set.
+func (p *Plugin) IsAuthorizedPlugin(pluginID string) bool {
+	conf := p.getConfig()
+	if conf.AllowAnyPlugin {
+		return true
+	}
+	_, ok := conf.AllowedPluginIds[pluginID]
+	if !ok {
+		p.API.LogWarn("Rejected a request from a plugin that is not in the list of allowed plugins", "plugin_id", pluginID)
+	}
+	return ok
+}
+
+// canManageScope reports whether userID is an admin of every team or
+// channel in scope. An empty scope applies everywhere, so it can only be
+// managed by plugin admins.
+func (p *Plugin) canManageScope(userID string, scope []string) (bool, error) {
+	if len(scope) == 0 {
+		return false, nil
+	}
+
+	for _, teamChannel := range scope {
+		split := strings.Split(teamChannel, "/")
+		if split[0] == "" || len(split) > 2 {
+			return false, nil
+		}
+
+		team, appErr := p.API.GetTeamByName(strings.ToLower(split[0]))
+		if appErr != nil {
+			return false, errors.Wrapf(appErr, "failed to get team `%s`", split[0])
+		}
+		if p.API.HasPermissionToTeam(userID, team.Id, model.PermissionManageTeam) {
+			continue
+		}
+
+		if len(split) != 2 || split[1] == "" {
+			return false, nil
+		}
+		channel, appErr := p.API.GetChannelByName(team.Id, strings.ToLower(split[1]), false)
+		if appErr != nil {
+			return false, errors.Wrapf(appErr, "failed to get channel `%s`", teamChannel)
+		}
+		if !p.API.HasPermissionToChannel(userID, channel.Id, model.PermissionManageChannelRoles) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isDelegatedAdmin reports whether the user running a command is an admin of
+// the team or the channel the command is run in.
+func (p *Plugin) isDelegatedAdmin(header *model.CommandArgs) bool {
+	if header.TeamId != "" && p.API.HasPermissionToTeam(header.UserId, header.TeamId, model.PermissionManageTeam) {
+		return true
+	}
+	return header.ChannelId != "" && p.API.HasPermissionToChannel(header.UserId, header.ChannelId, model.PermissionManageChannelRoles)
+}
+
+// delegatedScope returns the scope of links created by a team or channel
+// admin: the current team for team admins, the current channel otherwise.
+func (p *Plugin) delegatedScope(header *model.CommandArgs) ([]string, error) {
+	channelName, teamName, appErr := p.resolveScope(header.ChannelId)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to resolve the current team and channel")
+	}
+	if teamName == "" {
+		return nil, errors.New("links can only be scoped to channels that belong to a team")
+	}
+
+	if p.API.HasPermissionToTeam(header.UserId, header.TeamId, model.PermissionManageTeam) {
+		return []string{teamName}, nil
+	}
+	return []string{teamName + "/" + channelName}, nil
+}
+
+// permissionLevel is what a user may do with the links. Each level includes
+// the ones below it.
+type permissionLevel int
+
+const (
+	permissionNone permissionLevel = iota
+	// permissionView allows listing links and testing them.
+	permissionView
+	// permissionEdit allows adding, changing, enabling and disabling links.
+	permissionEdit
+	// permissionOwn allows everything, including deleting links.
+	permissionOwn
+)
+
+func (p *Plugin) getPermissionLevel(userID string) (permissionLevel, error) {
+	isAdmin, err := p.IsAuthorizedAdmin(userID)
+	if err != nil {
+		return permissionNone, err
+	}
+	if isAdmin {
+		return permissionOwn, nil
+	}
+
+	conf := p.getConfig()
+	if _, ok := conf.EditorUserIds[userID]; ok {
+		return permissionEdit, nil
+	}
+	if conf.Viewers == viewersEveryone {
+		return permissionView, nil
+	}
+
+	return permissionNone, nil
+}
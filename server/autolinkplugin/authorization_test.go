@@ -0,0 +1,235 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func setupDelegationTestAPI() *plugintest.API {
+	api := &plugintest.API{}
+	api.On("GetUser", "teamAdminId").Return(&model.User{Id: "teamAdminId", Roles: "system_user"}, nil)
+	api.On("GetUser", "channelAdminId").Return(&model.User{Id: "channelAdminId", Roles: "system_user"}, nil)
+	api.On("GetTeamByName", "eng").Return(&model.Team{Id: "engId", Name: "eng"}, nil)
+	api.On("GetTeamByName", "sales").Return(&model.Team{Id: "salesId", Name: "sales"}, nil)
+	api.On("GetChannelByName", "engId", "backend", false).Return(&model.Channel{Id: "backendId", Name: "backend", TeamId: "engId"}, nil)
+	api.On("GetChannelByName", "engId", "frontend", false).Return(&model.Channel{Id: "frontendId", Name: "frontend", TeamId: "engId"}, nil)
+	api.On("HasPermissionToTeam", "teamAdminId", "engId", model.PermissionManageTeam).Return(true)
+	api.On("HasPermissionToTeam", mock.AnythingOfType("string"), mock.AnythingOfType("string"), model.PermissionManageTeam).Return(false)
+	api.On("HasPermissionToChannel", "channelAdminId", "backendId", model.PermissionManageChannelRoles).Return(true)
+	api.On("HasPermissionToChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), model.PermissionManageChannelRoles).Return(false)
+	return api
+}
+
+func TestCanManageLink(t *testing.T) {
+	p := New()
+	p.SetAPI(setupDelegationTestAPI())
+
+	for _, tc := range []struct {
+		name     string
+		userID   string
+		scope    []string
+		expected bool
+	}{
+		{"team admin, own team", "teamAdminId", []string{"eng"}, true},
+		{"team admin, channel in own team", "teamAdminId", []string{"Eng/backend"}, true},
+		{"team admin, unscoped", "teamAdminId", nil, false},
+		{"team admin, other team", "teamAdminId", []string{"eng", "sales"}, false},
+		{"channel admin, own channel", "channelAdminId", []string{"eng/backend"}, true},
+		{"channel admin, whole team", "channelAdminId", []string{"eng"}, false},
+		{"channel admin, other channel", "channelAdminId", []string{"eng/backend", "eng/frontend"}, false},
+		{"empty team", "teamAdminId", []string{""}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			canManage, err := p.CanManageLink(tc.userID, autolink.Autolink{Scope: tc.scope})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, canManage)
+		})
+	}
+}
+
+func TestDelegatedCommands(t *testing.T) {
+	api := setupDelegationTestAPI()
+	api.On("GetChannel", "backendId").Return(&model.Channel{Id: "backendId", Name: "backend", TeamId: "engId"}, nil)
+	api.On("GetTeam", "engId").Return(&model.Team{Id: "engId", Name: "eng"}, nil)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	p.UpdateConfig(func(conf *Config) {
+		conf.Links = []autolink.Autolink{{
+			Name:     "Global",
+			Pattern:  "(global)",
+			Template: "GLOBAL",
+		}}
+	})
+
+	findLink := func(name string) autolink.Autolink {
+		for _, l := range p.getConfig().Links {
+			if l.Name == name {
+				return l
+			}
+		}
+		require.Failf(t, "link not found", name)
+		return autolink.Autolink{}
+	}
+
+	header := &model.CommandArgs{
+		UserId:    "channelAdminId",
+		TeamId:    "engId",
+		ChannelId: "backendId",
+	}
+
+	header.Command = "/autolink add Backend"
+	resp, _ := p.ExecuteCommand(nil, header)
+	assert.Contains(t, resp.Text, "Backend")
+	assert.Equal(t, []string{"eng/backend"}, findLink("Backend").Scope)
+
+	header.Command = "/autolink set Backend Scope eng"
+	resp, _ = p.ExecuteCommand(nil, header)
+	assert.Contains(t, resp.Text, "you can only manage links")
+	assert.Equal(t, []string{"eng/backend"}, findLink("Backend").Scope)
+
+	header.Command = "/autolink disable Global"
+	resp, _ = p.ExecuteCommand(nil, header)
+	assert.Contains(t, resp.Text, "you can only manage links")
+	assert.False(t, findLink("Global").Disabled)
+
+	header.Command = "/autolink list"
+	resp, _ = p.ExecuteCommand(nil, header)
+	assert.Contains(t, resp.Text, "Backend")
+	assert.NotContains(t, resp.Text, "Global")
+
+	header.UserId = "plainUserId"
+	api.On("GetUser", "plainUserId").Return(&model.User{Id: "plainUserId", Roles: "system_user"}, nil)
+	header.Command = "/autolink list"
+	resp, _ = p.ExecuteCommand(nil, header)
+	assert.Contains(t, resp.Text, "can only be executed")
+}
+
+func TestEditorsCantDelete(t *testing.T) {
+	api := setupDelegationTestAPI()
+	api.On("GetChannel", "backendId").Return(&model.Channel{Id: "backendId", Name: "backend", TeamId: "engId"}, nil)
+	api.On("GetTeam", "engId").Return(&model.Team{Id: "engId", Name: "eng"}, nil)
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	p.UpdateConfig(func(conf *Config) {
+		conf.EditorUserIds = map[string]struct{}{"channelAdminId": {}}
+		conf.Links = []autolink.Autolink{{
+			Name:     "Global",
+			Pattern:  "(global)",
+			Template: "GLOBAL",
+		}, {
+			Name:     "Backend",
+			Pattern:  "(backend)",
+			Template: "BACKEND",
+			Scope:    []string{"eng/backend"},
+		}}
+	})
+
+	// The editor is a channel admin, and may run delete as one
+	header := &model.CommandArgs{
+		UserId:    "channelAdminId",
+		TeamId:    "engId",
+		ChannelId: "backendId",
+	}
+	for _, command := range []string{"/autolink delete Global", "/autolink delete Backend", "/autolink delete 1-2"} {
+		header.Command = command
+		resp, _ := p.ExecuteCommand(nil, header)
+		assert.Contains(t, resp.Text, "you can't delete", command)
+	}
+	assert.Len(t, p.getConfig().Links, 2)
+
+	resp := p.submitBulkDialog(&model.SubmitDialogRequest{
+		UserId:    "channelAdminId",
+		TeamId:    "engId",
+		ChannelId: "backendId",
+		State:     `{"Action":"delete","Links":[{"Name":"Backend","Pattern":"(backend)","Template":"BACKEND","Scope":["eng/backend"]}]}`,
+	})
+	require.NotNil(t, resp)
+	assert.Contains(t, resp.Error, "you can't delete")
+	assert.Len(t, p.getConfig().Links, 2)
+}
+
+func TestCommandPermissions(t *testing.T) {
+	conf := Config{
+		PluginAdmins:             "adminId",
+		Editors:                  "editorId",
+		Viewers:                  viewersEveryone,
+		HideTemplatesFromViewers: true,
+		Links: []autolink.Autolink{{
+			Name:     "Visa",
+			Pattern:  "(?P<LastFour>4\\d{3})",
+			Template: "XXXX-$LastFour",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Username: "admin", Roles: "system_user"}, nil)
+	api.On("GetUser", "editorId").Return(&model.User{Id: "editorId", Roles: "system_user"}, nil)
+	api.On("GetUser", "viewerId").Return(&model.User{Id: "viewerId", Roles: "system_user"}, nil)
+	api.On("HasPermissionToTeam", mock.AnythingOfType("string"), mock.AnythingOfType("string"), model.PermissionManageTeam).Return(false)
+	api.On("HasPermissionToChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), model.PermissionManageChannelRoles).Return(false)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	api.On("GetDirectChannel", "editorId", "botUserId").Return(&model.Channel{Id: "dmChannelId"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{}, nil)
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	p.botUserID = "botUserId"
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(userID, command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    userID,
+			TeamId:    "teamId",
+			ChannelId: "channelId",
+			Command:   command,
+		})
+		return resp.Text
+	}
+
+	t.Run("viewer", func(t *testing.T) {
+		text := run("viewerId", "/autolink list")
+		assert.Contains(t, text, "Visa")
+		assert.Contains(t, text, hiddenValue)
+		assert.NotContains(t, text, "LastFour")
+
+		assert.Contains(t, run("viewerId", "/autolink test Visa 4111"), "XXXX-4111")
+		assert.Contains(t, run("viewerId", "/autolink disable Visa"), "You do not have permission")
+	})
+
+	t.Run("editor", func(t *testing.T) {
+		text := run("editorId", "/autolink list")
+		assert.Contains(t, text, "LastFour")
+
+		assert.Contains(t, run("editorId", "/autolink add Jira"), "Jira")
+		assert.Contains(t, run("editorId", "/autolink disable Jira"), "Disabled")
+		assert.Contains(t, run("editorId", "/autolink delete Jira"), "You do not have permission")
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		assert.Contains(t, run("adminId", "/autolink delete Jira"), "removed")
+
+		// The editor added Jira, and is told that the admin deleted it
+		api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dmChannelId" && post.Message == "Your link **Jira** was deleted by @admin."
+		}))
+	})
+}
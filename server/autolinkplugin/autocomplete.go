@@ -0,0 +1,122 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// autocompleteURL is the path, relative to the plugin, of the dynamic lists
+// used in the autocomplete of the /autolink command.
+const autocompleteURL = "api/v1/autocomplete/"
+
+// Arguments with dynamic autocomplete lists.
+const (
+	// autocompleteLinks lists the links the user can see.
+	autocompleteLinks = "links"
+	// autocompleteList lists the links and the fields `/autolink list`
+	// can search.
+	autocompleteList = "list"
+	// autocompleteOptional lists the links users can turn off for their
+	// own posts.
+	autocompleteOptional = "optional"
+	// autocompleteTest lists the links and the option to test them all.
+	autocompleteTest = "test"
+	// autocompleteGroups lists the link groups.
+	autocompleteGroups = "groups"
+)
+
+// Autocomplete returns the suggestions for the named dynamic autocomplete
+// argument, as seen by userID.
+func (p *Plugin) Autocomplete(userID, argument string) ([]model.AutocompleteListItem, error) {
+	switch argument {
+	case autocompleteLinks:
+		return p.autocompleteLinks(userID)
+	case autocompleteList:
+		items, err := p.autocompleteLinks(userID)
+		if err != nil {
+			return nil, err
+		}
+		return append(items, model.AutocompleteListItem{
+			Item:     optTemplate,
+			Hint:     "[value]",
+			HelpText: "List the links whose template contains value",
+		}, model.AutocompleteListItem{
+			Item:     optPattern,
+			Hint:     "[value]",
+			HelpText: "List the links whose pattern contains value",
+		}), nil
+	case autocompleteTest:
+		items, err := p.autocompleteLinks(userID)
+		if err != nil {
+			return nil, err
+		}
+		return append([]model.AutocompleteListItem{{
+			Item:     optAll,
+			Hint:     "[text]",
+			HelpText: "Apply all the enabled links, and show each step",
+		}}, items...), nil
+	case autocompleteGroups:
+		items := []model.AutocompleteListItem{}
+		conf := p.getConfig()
+		for _, g := range conf.Groups {
+			item := model.AutocompleteListItem{
+				Item:     quoteArg(g.Name),
+				HelpText: fmt.Sprintf("Links: %d", len(groupMembers(conf.Links, g.Name))),
+			}
+			if g.Disabled {
+				item.Hint = "(disabled)"
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case autocompleteOptional:
+		items := []model.AutocompleteListItem{}
+		for _, l := range p.getConfig().Links {
+			if l.IsUserOptional() && !l.Effective().Disabled && l.Name != "" {
+				items = append(items, model.AutocompleteListItem{Item: quoteArg(l.Name)})
+			}
+		}
+		return items, nil
+	}
+	return nil, errors.Errorf("unknown autocomplete argument %q", argument)
+}
+
+// autocompleteLinks lists the links userID can see, by name, with their
+// numbers in the `/autolink list` output.
+func (p *Plugin) autocompleteLinks(userID string) ([]model.AutocompleteListItem, error) {
+	level, err := p.getPermissionLevel(userID)
+	if err != nil {
+		return nil, err
+	}
+	hideTemplates := level == permissionView && p.getConfig().HideTemplatesFromViewers
+
+	items := []model.AutocompleteListItem{}
+	for i, l := range currentLinks(p) {
+		if level == permissionNone {
+			// Team and channel admins only see the links they can manage
+			canManage, scopeErr := p.canManageScope(userID, l.Scope)
+			if scopeErr != nil || !canManage {
+				continue
+			}
+		}
+
+		item := model.AutocompleteListItem{
+			Item: quoteArg(l.Name),
+			Hint: fmt.Sprintf("#%d", i+1),
+		}
+		if item.Item == "" {
+			item.Item = strconv.Itoa(i + 1)
+		}
+		if l.Effective().Disabled {
+			item.Hint += " (disabled)"
+		}
+		if !hideTemplates {
+			item.HelpText = l.Pattern
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,100 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestAutocomplete(t *testing.T) {
+	api := setupDelegationTestAPI()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("GetUser", "viewerId").Return(&model.User{Id: "viewerId", Roles: "system_user"}, nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+
+	p := New()
+	p.SetAPI(api)
+	p.UpdateConfig(func(conf *Config) {
+		conf.Links = []autolink.Autolink{{
+			Name:    "Backend",
+			Pattern: "(backend)",
+			Scope:   []string{"eng/backend"},
+		}, {
+			Name:     "Jira",
+			Pattern:  "(MM-\\d+)",
+			Disabled: true,
+		}, {
+			Name:         "Sales",
+			Pattern:      "(sales)",
+			Scope:        []string{"sales"},
+			UserOptional: true,
+		}}
+	})
+
+	t.Run("links", func(t *testing.T) {
+		items, err := p.Autocomplete("adminId", autocompleteLinks)
+		require.NoError(t, err)
+		assert.Equal(t, []model.AutocompleteListItem{
+			{Item: "Backend", Hint: "#1", HelpText: "(backend)"},
+			{Item: "Jira", Hint: "#2 (disabled)", HelpText: "(MM-\\d+)"},
+			{Item: "Sales", Hint: "#3", HelpText: "(sales)"},
+		}, items)
+	})
+
+	t.Run("hidden from viewers", func(t *testing.T) {
+		p.UpdateConfig(func(conf *Config) {
+			conf.Viewers = viewersEveryone
+			conf.HideTemplatesFromViewers = true
+		})
+		defer p.UpdateConfig(func(conf *Config) {
+			conf.Viewers = ""
+			conf.HideTemplatesFromViewers = false
+		})
+
+		items, err := p.Autocomplete("viewerId", autocompleteLinks)
+		require.NoError(t, err)
+		require.Len(t, items, 3)
+		for _, item := range items {
+			assert.Empty(t, item.HelpText)
+		}
+	})
+
+	t.Run("delegated admin", func(t *testing.T) {
+		items, err := p.Autocomplete("channelAdminId", autocompleteLinks)
+		require.NoError(t, err)
+		assert.Equal(t, []model.AutocompleteListItem{
+			{Item: "Backend", Hint: "#1", HelpText: "(backend)"},
+		}, items)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		items, err := p.Autocomplete("adminId", autocompleteList)
+		require.NoError(t, err)
+		require.Len(t, items, 5)
+		assert.Equal(t, optTemplate, items[3].Item)
+		assert.Equal(t, optPattern, items[4].Item)
+	})
+
+	t.Run("test", func(t *testing.T) {
+		items, err := p.Autocomplete("adminId", autocompleteTest)
+		require.NoError(t, err)
+		require.Len(t, items, 4)
+		assert.Equal(t, optAll, items[0].Item)
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		items, err := p.Autocomplete("viewerId", autocompleteOptional)
+		require.NoError(t, err)
+		assert.Equal(t, []model.AutocompleteListItem{{Item: "Sales"}}, items)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := p.Autocomplete("adminId", "unknown")
+		require.Error(t, err)
+	})
+}
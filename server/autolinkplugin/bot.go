@@ -0,0 +1,112 @@
+package autolinkplugin
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	botUsername    = "autolink"
+	botDisplayName = "Autolink"
+	botDescription = "Created by the Autolink plugin."
+)
+
+// sendDirectMessage posts message as the plugin bot in the DM channel
+// between the bot and userID.
+func (p *Plugin) sendDirectMessage(userID, message string) error {
+	if p.botUserID == "" {
+		return errors.New("the plugin bot is not available")
+	}
+
+	channel, appErr := p.API.GetDirectChannel(userID, p.botUserID)
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to get direct channel for user `%s`", userID)
+	}
+
+	_, appErr = p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+	})
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to send direct message to user `%s`", userID)
+	}
+
+	return nil
+}
+
+// notifyAdmins sends message to every plugin admin. If no plugin admins are
+// configured, the system admins are notified instead.
+func (p *Plugin) notifyAdmins(message string) {
+	userIDs := []string{}
+	for userID := range p.getConfig().AdminUserIds {
+		userIDs = append(userIDs, userID)
+	}
+
+	if len(userIDs) == 0 {
+		admins, appErr := p.API.GetUsers(&model.UserGetOptions{
+			Role:    model.SystemAdminRoleId,
+			Active:  true,
+			PerPage: 100,
+		})
+		if appErr != nil {
+			p.API.LogError("Failed to get the list of system admins", "error", appErr.Error())
+			return
+		}
+		for _, admin := range admins {
+			userIDs = append(userIDs, admin.Id)
+		}
+	}
+
+	for _, userID := range userIDs {
+		if err := p.sendDirectMessage(userID, message); err != nil {
+			p.API.LogError("Failed to notify plugin admin", "error", err.Error())
+		}
+	}
+}
+
+// notifyChannel posts message as the plugin bot in the configured
+// notification channel. Nothing is posted if no channel is configured.
+func (p *Plugin) notifyChannel(message string) {
+	name := p.getConfig().NotificationChannel
+	if name == "" {
+		return
+	}
+	if err := p.postToChannel(name, message); err != nil {
+		p.API.LogError("Failed to post to the notification channel", "channel", name, "error", err.Error())
+	}
+}
+
+// postToChannel posts message as the plugin bot in the channel named
+// team-name/channel-name.
+func (p *Plugin) postToChannel(name, message string) error {
+	if p.botUserID == "" {
+		return errors.New("the plugin bot is not available")
+	}
+
+	teamName, channelName, ok := strings.Cut(name, "/")
+	if !ok || teamName == "" || channelName == "" {
+		return errors.Errorf("%q is not a valid channel, must be team-name/channel-name", name)
+	}
+	team, appErr := p.API.GetTeamByName(strings.ToLower(teamName))
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to get team `%s`", teamName)
+	}
+	channel, appErr := p.API.GetChannelByName(team.Id, strings.ToLower(channelName), false)
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to get channel `%s`", name)
+	}
+
+	_, appErr = p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+	})
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to post to channel `%s`", name)
+	}
+
+	return nil
+}
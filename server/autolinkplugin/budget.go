@@ -0,0 +1,101 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// maxLinkOverruns is the number of consecutive posts in which a link may
+// exceed its share of the processing time budget before it is disabled.
+const maxLinkOverruns = 5
+
+func enabledLinkCount(links []autolink.Autolink) int {
+	n := 0
+	for _, l := range links {
+		if !l.Disabled {
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// recordLinkOverruns updates the overrun counters with the time each link
+// took while processing a post, and returns the names of the links that have
+// exceeded their share too many times in a row. Links that were not
+// evaluated at all leave their counters untouched. Mandatory links are never
+// disabled, they are not counted.
+func (p *Plugin) recordLinkOverruns(links []autolink.Autolink, elapsed []time.Duration, share time.Duration) []string {
+	p.overrunsLock.Lock()
+	defer p.overrunsLock.Unlock()
+
+	tripped := []string{}
+	for i, l := range links {
+		if l.Disabled || l.IsMandatory() || elapsed[i] == 0 {
+			continue
+		}
+
+		name := l.DisplayName()
+		if elapsed[i] <= share {
+			delete(p.overruns, name)
+			continue
+		}
+
+		p.overruns[name]++
+		if p.overruns[name] >= maxLinkOverruns {
+			delete(p.overruns, name)
+			tripped = append(tripped, name)
+		}
+	}
+
+	return tripped
+}
+
+// disableSlowLinks disables the named links and tells the plugin admins why.
+// Mandatory links are left enabled.
+func (p *Plugin) disableSlowLinks(names []string, share time.Duration) {
+	links := append([]autolink.Autolink{}, p.GetLinks()...)
+
+	disabled := []autolink.Autolink{}
+	enabled := []autolink.Autolink{}
+	for i := range links {
+		if links[i].Disabled || links[i].IsMandatory() {
+			continue
+		}
+		for _, name := range names {
+			if links[i].DisplayName() == name {
+				enabled = append(enabled, links[i])
+				links[i].Disabled = true
+				disabled = append(disabled, links[i])
+				break
+			}
+		}
+	}
+	if len(disabled) == 0 {
+		return
+	}
+
+	if err := p.SaveLinks(links); err != nil {
+		p.API.LogError("Failed to disable slow links", "error", err.Error())
+		return
+	}
+
+	adminUserIds := p.getConfig().AdminUserIds
+	for i, l := range disabled {
+		p.auditLinkChange(auditSourcePlugin, "", "", "disable", &enabled[i], &disabled[i], auditResultSuccess)
+		p.API.LogWarn("Disabled a link that repeatedly exceeded its processing time budget", "link", l.DisplayName())
+		message := fmt.Sprintf(
+			"Link **%s** was disabled because it took longer than its share of the processing time budget (%v) in %d consecutive posts. "+
+				"Review its pattern, then re-enable it with `/autolink enable %s`.\n%s",
+			l.DisplayName(), share, maxLinkOverruns, l.DisplayName(), l.ToMarkdown(0))
+		p.notifyAdmins(message)
+		p.notifyChannel(message)
+		if _, isAdmin := adminUserIds[l.Owner]; !isAdmin {
+			p.notifyOwner(l, message)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package autolinkplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestRecordLinkOverruns(t *testing.T) {
+	links := []autolink.Autolink{{
+		Name: "slow",
+	}, {
+		Name: "fast",
+	}, {
+		Name:     "disabled",
+		Disabled: true,
+	}, {
+		Name:   "mandatory",
+		Action: autolink.ActionRedact,
+	}}
+	share := time.Millisecond
+
+	p := New()
+	for i := 1; i < maxLinkOverruns; i++ {
+		tripped := p.recordLinkOverruns(links, []time.Duration{2 * share, share / 2, 2 * share, 2 * share}, share)
+		assert.Empty(t, tripped)
+	}
+
+	t.Run("a link within its share resets the count", func(t *testing.T) {
+		p := New()
+		for i := 1; i < maxLinkOverruns; i++ {
+			p.recordLinkOverruns(links, []time.Duration{2 * share, 0, 0, 0}, share)
+		}
+		assert.Empty(t, p.recordLinkOverruns(links, []time.Duration{share / 2, 0, 0, 0}, share))
+		assert.Empty(t, p.recordLinkOverruns(links, []time.Duration{2 * share, 0, 0, 0}, share))
+	})
+
+	t.Run("a link that is not evaluated keeps its count", func(t *testing.T) {
+		assert.Empty(t, p.recordLinkOverruns(links, []time.Duration{0, 0, 0, 0}, share))
+	})
+
+	tripped := p.recordLinkOverruns(links, []time.Duration{2 * share, share / 2, 2 * share, 2 * share}, share)
+	assert.Equal(t, []string{"slow"}, tripped)
+}
+
+func TestDisableSlowLinks(t *testing.T) {
+	conf := Config{
+		PluginAdmins: "adminId",
+		Links: []autolink.Autolink{{
+			Name:     "slow",
+			Pattern:  "(slow)",
+			Template: "fast",
+		}, {
+			Name:     "fast",
+			Pattern:  "(fast)",
+			Template: "slow",
+		}, {
+			Name:     "mask",
+			Pattern:  "(secret)",
+			Template: "XXX",
+			Action:   autolink.ActionRedact,
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId"}, nil)
+	api.On("EnsureBotUser", mock.AnythingOfType("*model.Bot")).Return("botUserId", nil)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockAuditLog(api)
+	api.On("LogWarn", mock.AnythingOfType("string"), "link", "slow").Return()
+	api.On("GetDirectChannel", "adminId", "botUserId").Return(&model.Channel{Id: "dmChannelId"}, nil)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "dmChannelId" && post.UserId == "botUserId"
+	})).Return(&model.Post{}, nil).Once()
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+	require.NoError(t, p.OnActivate())
+
+	p.disableSlowLinks([]string{"slow", "mask"}, time.Millisecond)
+
+	links := p.GetLinks()
+	assert.True(t, links[0].Disabled)
+	assert.False(t, links[1].Disabled)
+	assert.False(t, links[2].Disabled, "mandatory links are never disabled")
+	api.AssertNumberOfCalls(t, "SavePluginConfig", 1)
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+}
+
+func TestProcessPostTimeBudget(t *testing.T) {
+	conf := Config{
+		ProcessingTimeBudget: 1,
+		Links: []autolink.Autolink{{
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{}, nil)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	post := &model.Post{Message: "Welcome to Mattermost!"}
+	rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post)
+	assert.Equal(t, "Welcome to [Mattermost](https://mattermost.com)!", rpost.Message)
+}
+
+func TestRedactLinksIgnoreTimeBudget(t *testing.T) {
+	conf := Config{
+		ProcessingTimeBudget: 1,
+		Links: []autolink.Autolink{{
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}, {
+			Name:     "SSN",
+			Pattern:  "(?P<part1>\\d{3})-(?P<part2>\\d{2})-(?P<LastFour>\\d{4})",
+			Template: "XXX-XX-$LastFour",
+			Action:   autolink.ActionRedact,
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{}, nil)
+	api.On("LogWarn", "Post processing exceeded the time budget, remaining links were skipped", "post_id", "", "budget_ms", 1).Return()
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	// The budget is exhausted before the first link is applied
+	post := &model.Post{Message: "Mattermost\n\nSSN 652-47-3356"}
+	result := p.replaceLinks(post, p.getConfig(), time.Now().Add(-time.Second), nil)
+	assert.Equal(t, "Mattermost\n\nSSN XXX-XX-3356", result.Message)
+	require.Len(t, result.Redactions, 1)
+	api.AssertCalled(t, "LogWarn", "Post processing exceeded the time budget, remaining links were skipped", "post_id", "", "budget_ms", 1)
+}
@@ -0,0 +1,318 @@
+package autolinkplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const dialogCallbackBulk = "bulk"
+
+// Prefixes of the selectors of several links at once.
+const (
+	selectorName     = "name:"
+	selectorScope    = "scope:"
+	selectorPattern  = "pattern:"
+	selectorTemplate = "template:"
+)
+
+var selectorRange = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+// isLinkSelector returns true if ref selects links in bulk rather than
+// referring to a single link.
+func isLinkSelector(ref string) bool {
+	for _, prefix := range []string{selectorName, selectorScope, selectorPattern, selectorTemplate} {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	return selectorRange.MatchString(ref)
+}
+
+// searchLinkSelector returns the links and the indexes of those
+// selected by selector:
+//   - name:JIRA-* selects the links whose name matches the wildcard pattern,
+//     or contains the value if it has no wildcards,
+//   - scope:eng selects the links scoped to the team eng or one of its
+//     channels, scope:eng/town-square to the channel,
+//   - pattern:atlassian and template:atlassian select the links whose
+//     pattern or template contains the value,
+//   - 3-9 selects the links numbered 3 to 9 in the `/autolink list` output.
+func searchLinkSelector(p *Plugin, selector string) ([]autolink.Autolink, []int, error) {
+	switch {
+	case strings.HasPrefix(selector, selectorName):
+		value := strings.TrimPrefix(selector, selectorName)
+		if !strings.ContainsAny(value, "*?[") {
+			return searchLinkRef(p, false, value)
+		}
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, nil, errors.Errorf("invalid name pattern %q", value)
+		}
+
+		links := currentLinks(p)
+		found := []int{}
+		for i, l := range links {
+			if matched, _ := path.Match(value, l.Name); matched {
+				found = append(found, i)
+			}
+		}
+		if len(found) == 0 {
+			return nil, nil, errors.Errorf("no link name matches %q", value)
+		}
+		return links, found, nil
+
+	case strings.HasPrefix(selector, selectorScope):
+		value := strings.ToLower(strings.TrimPrefix(selector, selectorScope))
+		if value == "" {
+			return nil, nil, errors.New("a team or team/channel must be specified after scope:")
+		}
+
+		links := currentLinks(p)
+		found := []int{}
+		for i, l := range links {
+			for _, scope := range l.Scope {
+				scope = strings.ToLower(scope)
+				if scope == value || strings.HasPrefix(scope, value+"/") {
+					found = append(found, i)
+					break
+				}
+			}
+		}
+		if len(found) == 0 {
+			return nil, nil, errors.Errorf("no link is scoped to %q", value)
+		}
+		return links, found, nil
+
+	case strings.HasPrefix(selector, selectorPattern):
+		return searchLinkRefByTemplateOrPattern(p, optPattern, strings.TrimPrefix(selector, selectorPattern))
+
+	case strings.HasPrefix(selector, selectorTemplate):
+		return searchLinkRefByTemplateOrPattern(p, optTemplate, strings.TrimPrefix(selector, selectorTemplate))
+	}
+
+	bounds := selectorRange.FindStringSubmatch(selector)
+	if bounds == nil {
+		return nil, nil, errors.Errorf("%q is not a link selector", selector)
+	}
+	links := currentLinks(p)
+	from, _ := strconv.Atoi(bounds[1])
+	to, _ := strconv.Atoi(bounds[2])
+	if from < 1 || from > to || to > len(links) {
+		return nil, nil, errors.Errorf("%v is not a valid range of link numbers, there are %d links", selector, len(links))
+	}
+	found := []int{}
+	for i := from - 1; i < to; i++ {
+		found = append(found, i)
+	}
+	return links, found, nil
+}
+
+// bulkChange is a change of several links, confirmed in a dialog before it
+// is applied.
+type bulkChange struct {
+	// Action is enable, disable, delete or set.
+	Action string `json:"action"`
+	// Field, Value and Args are the arguments of set.
+	Field string   `json:"field,omitempty"`
+	Value string   `json:"value,omitempty"`
+	Args  []string `json:"args,omitempty"`
+	// Links are the selected links, as they were when the dialog was
+	// opened.
+	Links []autolink.Autolink `json:"links"`
+}
+
+var bulkActionLabels = map[string]string{
+	"enable":  "Enable",
+	"disable": "Disable",
+	"delete":  "Delete",
+	"set":     "Set",
+}
+
+var bulkResults = map[string]string{
+	"enable":  "Enabled",
+	"disable": "Disabled",
+	"delete":  "Deleted",
+	"set":     "Changed",
+}
+
+// apply applies the change to l. Deletions are applied by the caller.
+func (change *bulkChange) apply(l *autolink.Autolink) error {
+	switch change.Action {
+	case "enable":
+		l.Disabled = false
+	case "disable":
+		l.Disabled = true
+	case "set":
+		if change.Field == "Name" {
+			return errors.New("several links can't be given the same name, rename them one at a time with `/autolink rename`")
+		}
+		return setLinkField(l, change.Field, change.Value, change.Args)
+	}
+	return nil
+}
+
+// confirmBulkChange checks that the user running the command may change the
+// links selected by selector, and opens a dialog listing them to confirm the
+// change.
+func (p *Plugin) confirmBulkChange(header *model.CommandArgs, selector string, change bulkChange) *model.CommandResponse {
+	links, refs, err := searchLinkSelector(p, selector)
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	list := ""
+	for _, i := range refs {
+		before := links[i]
+		if err = authorizeLinkChange(p, header, change.Action, before); err != nil {
+			return responsef("%v", err)
+		}
+		after := before
+		if err = change.apply(&after); err != nil {
+			return responsef("%v", err)
+		}
+		if err = p.getConfig().checkLinkGroup(after); err != nil {
+			return responsef("%v", err)
+		}
+		if change.Action != "delete" {
+			if err = authorizeLinkChange(p, header, change.Action, after); err != nil {
+				return responsef("%v", err)
+			}
+		}
+
+		change.Links = append(change.Links, before)
+		list += fmt.Sprintf("%d. **%s**", i+1, before.DisplayName())
+		if before.Disabled {
+			list += " (disabled)"
+		}
+		list += "\n"
+	}
+
+	state, err := json.Marshal(change)
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	what := strings.ToLower(bulkActionLabels[change.Action])
+	if change.Action == "set" {
+		what = fmt.Sprintf("set `%s` to `%s` in", change.Field, change.Value)
+	}
+	appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: header.TriggerId,
+		URL:       linkDialogURL,
+		Dialog: model.Dialog{
+			CallbackId:       dialogCallbackBulk,
+			Title:            fmt.Sprintf("%s %d links", bulkActionLabels[change.Action], len(refs)),
+			IntroductionText: fmt.Sprintf("This will %s the following links:\n%s", what, list),
+			SubmitLabel:      bulkActionLabels[change.Action],
+			State:            string(state),
+		},
+	})
+	if appErr != nil {
+		return responsef("failed to open the dialog: %v", appErr)
+	}
+	return &model.CommandResponse{}
+}
+
+// submitBulkDialog applies a confirmed bulk change, and saves the links
+// once.
+func (p *Plugin) submitBulkDialog(request *model.SubmitDialogRequest) *model.SubmitDialogResponse {
+	var change bulkChange
+	if err := json.Unmarshal([]byte(request.State), &change); err != nil {
+		return &model.SubmitDialogResponse{Error: "Invalid dialog state, please try again."}
+	}
+
+	header := &model.CommandArgs{
+		UserId:    request.UserId,
+		TeamId:    request.TeamId,
+		ChannelId: request.ChannelId,
+	}
+	if bulkActionLabels[change.Action] == "" {
+		return &model.SubmitDialogResponse{Error: "Invalid dialog state, please try again."}
+	}
+
+	level, err := p.getPermissionLevel(request.UserId)
+	if err != nil {
+		return &model.SubmitDialogResponse{Error: err.Error()}
+	}
+	if level < commandPermissions[change.Action] && !(delegatedCommands[change.Action] && p.isDelegatedAdmin(header)) {
+		p.audit(AuditEvent{
+			Source: auditSourceCommand,
+			UserID: request.UserId,
+			Action: change.Action,
+			Result: auditResultDenied,
+		})
+		return &model.SubmitDialogResponse{Error: "You do not have permission to manage links."}
+	}
+
+	links := append([]autolink.Autolink{}, p.getConfig().Links...)
+	changed := make([]bool, len(links))
+	afters := []autolink.Autolink{}
+	for _, before := range change.Links {
+		i := -1
+		for j := range links {
+			if !changed[j] && links[j].Equals(before) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return &model.SubmitDialogResponse{Error: fmt.Sprintf("The link %s was changed or deleted since the dialog was opened, please run the command again.", before.DisplayName())}
+		}
+		changed[i] = true
+
+		if err = authorizeLinkChange(p, header, change.Action, before); err != nil {
+			return &model.SubmitDialogResponse{Error: err.Error()}
+		}
+		after := before
+		if err = change.apply(&after); err != nil {
+			return &model.SubmitDialogResponse{Error: err.Error()}
+		}
+		if change.Action != "delete" {
+			if err = authorizeLinkChange(p, header, change.Action, after); err != nil {
+				return &model.SubmitDialogResponse{Error: err.Error()}
+			}
+		}
+		links[i] = after
+		afters = append(afters, after)
+	}
+
+	if change.Action == "delete" {
+		kept := []autolink.Autolink{}
+		for i, l := range links {
+			if !changed[i] {
+				kept = append(kept, l)
+			}
+		}
+		links = kept
+	}
+
+	if err = saveConfigLinks(p, links); err != nil {
+		return &model.SubmitDialogResponse{Error: err.Error()}
+	}
+
+	message := fmt.Sprintf("%s %d links:\n", bulkResults[change.Action], len(change.Links))
+	for i := range change.Links {
+		before := change.Links[i]
+		if change.Action == "delete" {
+			p.auditLinkChange(auditSourceCommand, request.UserId, "", change.Action, &before, nil, auditResultSuccess)
+		} else {
+			p.auditLinkChange(auditSourceCommand, request.UserId, "", change.Action, &before, &afters[i], auditResultSuccess)
+		}
+		message += fmt.Sprintf("- **%s**\n", before.DisplayName())
+	}
+	p.API.SendEphemeralPost(request.UserId, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: request.ChannelId,
+		Message:   message,
+	})
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func bulkTestLinks() []autolink.Autolink {
+	return []autolink.Autolink{{
+		Name:     "GitHub",
+		Pattern:  "(#\\d+)",
+		Template: "https://github.com/$1",
+	}, {
+		Name:     "JIRA-DEV",
+		Pattern:  "(DEV-\\d+)",
+		Template: "https://example.atlassian.net/browse/$1",
+		Scope:    []string{"eng/backend"},
+	}, {
+		Name:     "JIRA-OPS",
+		Pattern:  "(OPS-\\d+)",
+		Template: "https://example.atlassian.net/browse/$1",
+		Scope:    []string{"eng"},
+	}, {
+		Name:     "Zendesk",
+		Pattern:  "(ZD-\\d+)",
+		Template: "https://zendesk/$1",
+		Scope:    []string{"Sales"},
+	}}
+}
+
+func TestSearchLinkSelector(t *testing.T) {
+	p := New()
+	p.UpdateConfig(func(conf *Config) {
+		conf.Links = bulkTestLinks()
+	})
+
+	for _, tc := range []struct {
+		selector string
+		expected []int
+		err      string
+	}{
+		{"name:JIRA-*", []int{1, 2}, ""},
+		{"name:*-OPS", []int{2}, ""},
+		{"name:JIRA", []int{1, 2}, ""},
+		{"name:Jira-*", nil, "no link name matches"},
+		{"name:[", nil, "invalid name pattern"},
+		{"scope:eng", []int{1, 2}, ""},
+		{"scope:ENG/backend", []int{1}, ""},
+		{"scope:sales", []int{3}, ""},
+		{"scope:en", nil, "no link is scoped"},
+		{"scope:", nil, "must be specified"},
+		{"pattern:-\\d", []int{1, 2, 3}, ""},
+		{"template:atlassian", []int{1, 2}, ""},
+		{"template:nowhere", nil, "not found"},
+		{"2-3", []int{1, 2}, ""},
+		{"1-1", []int{0}, ""},
+		{"3-2", nil, "not a valid range"},
+		{"0-2", nil, "not a valid range"},
+		{"2-5", nil, "not a valid range"},
+	} {
+		t.Run(tc.selector, func(t *testing.T) {
+			require.True(t, isLinkSelector(tc.selector))
+			_, refs, err := searchLinkSelector(p, tc.selector)
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, refs)
+		})
+	}
+
+	assert.False(t, isLinkSelector("Jira"))
+	assert.False(t, isLinkSelector("3"))
+}
+
+func TestBulkCommands(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links:              bulkTestLinks(),
+	}
+
+	var opened model.OpenDialogRequest
+	var ephemeral *model.Post
+	saved := 0
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	api.On("HasPermissionToTeam", "userId", mock.AnythingOfType("string"), model.PermissionManageTeam).Return(false)
+	api.On("HasPermissionToChannel", "userId", mock.AnythingOfType("string"), model.PermissionManageChannelRoles).Return(false)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(func(map[string]interface{}) *model.AppError {
+		saved++
+		return nil
+	})
+	api.On("OpenInteractiveDialog", mock.AnythingOfType("model.OpenDialogRequest")).Return(func(request model.OpenDialogRequest) *model.AppError {
+		opened = request
+		return nil
+	})
+	api.On("SendEphemeralPost", "adminId", mock.AnythingOfType("*model.Post")).Return(func(_ string, post *model.Post) *model.Post {
+		ephemeral = post
+		return post
+	})
+	getAuditLog := mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) *model.CommandResponse {
+		opened = model.OpenDialogRequest{}
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    "adminId",
+			ChannelId: "channelId",
+			TriggerId: "triggerId",
+			Command:   command,
+		})
+		return resp
+	}
+	confirm := func(userID string) *model.SubmitDialogResponse {
+		return p.SubmitLinkDialog(&model.SubmitDialogRequest{
+			UserId:     userID,
+			ChannelId:  "channelId",
+			CallbackId: opened.Dialog.CallbackId,
+			State:      opened.Dialog.State,
+		})
+	}
+
+	t.Run("disable", func(t *testing.T) {
+		resp := run("/autolink disable name:JIRA-*")
+		assert.Empty(t, resp.Text)
+		assert.Equal(t, "Disable 2 links", opened.Dialog.Title)
+		assert.Equal(t, "This will disable the following links:\n2. **JIRA-DEV**\n3. **JIRA-OPS**\n", opened.Dialog.IntroductionText)
+		assert.Equal(t, 0, saved)
+
+		require.Nil(t, confirm("adminId"))
+		assert.Equal(t, 1, saved)
+		links := p.getConfig().Links
+		assert.False(t, links[0].Disabled)
+		assert.True(t, links[1].Disabled)
+		assert.True(t, links[2].Disabled)
+		assert.Equal(t, "Disabled 2 links:\n- **JIRA-DEV**\n- **JIRA-OPS**\n", ephemeral.Message)
+
+		events := getAuditLog()
+		require.Len(t, events, 2)
+		assert.Equal(t, "disable", events[1].Action)
+		assert.Equal(t, "JIRA-OPS", events[1].Link)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		run("/autolink enable scope:eng")
+		require.NotEmpty(t, opened.Dialog.State)
+		confirmation := opened
+
+		run("/autolink enable JIRA-OPS")
+		opened = confirmation
+		resp := confirm("adminId")
+		require.NotNil(t, resp)
+		assert.Contains(t, resp.Error, "JIRA-OPS was changed or deleted")
+		assert.True(t, p.getConfig().Links[1].Disabled)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		run("/autolink set template:atlassian Template https://jira.example.com/browse/$1")
+		assert.Equal(t, "Set 2 links", opened.Dialog.Title)
+		require.Nil(t, confirm("adminId"))
+
+		links := p.getConfig().Links
+		assert.Equal(t, "https://jira.example.com/browse/$1", links[1].Template)
+		assert.Equal(t, "https://jira.example.com/browse/$1", links[2].Template)
+		assert.Equal(t, "https://github.com/$1", links[0].Template)
+	})
+
+	t.Run("invalid set", func(t *testing.T) {
+		resp := run("/autolink set 1-2 WordMatch maybe")
+		assert.Contains(t, resp.Text, "Not a bool")
+		assert.Empty(t, opened.Dialog.State)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		run("/autolink delete 1-4")
+		resp := confirm("userId")
+		require.NotNil(t, resp)
+		assert.Equal(t, "You do not have permission to manage links.", resp.Error)
+		assert.Len(t, p.getConfig().Links, 4)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		saved = 0
+		run("/autolink delete 2-3")
+		assert.Equal(t, "This will delete the following links:\n2. **JIRA-DEV** (disabled)\n3. **JIRA-OPS**\n", opened.Dialog.IntroductionText)
+		require.Nil(t, confirm("adminId"))
+		assert.Equal(t, 1, saved)
+
+		links := p.getConfig().Links
+		require.Len(t, links, 2)
+		assert.Equal(t, "GitHub", links[0].Name)
+		assert.Equal(t, "Zendesk", links[1].Name)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp := run("/autolink enable name:JIRA-*")
+		assert.Contains(t, resp.Text, "no link name matches")
+	})
+}
@@ -2,6 +2,7 @@ package autolinkplugin
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -13,29 +14,51 @@ import (
 )
 
 const (
-	autolinkCommand         = "/autolink"
-	optName                 = "Name"
-	optTemplate             = "Template"
-	optPattern              = "Pattern"
-	optScope                = "Scope"
-	optDisabled             = "Disabled"
-	optProcessBotPosts      = "ProcessBotPosts"
-	optDisableNonWordPrefix = "DisableNonWordPrefix"
-	optDisableNonWordSuffix = "DisableNonWordSuffix"
-	optWordMatch            = "WordMatch"
+	autolinkCommand = "/autolink"
+	optTemplate     = "Template"
+	optPattern      = "Pattern"
 )
 
+// hiddenValue replaces the Pattern and Template of links listed to viewers,
+// if HideTemplatesFromViewers is set.
+const hiddenValue = "(hidden)"
+
 const helpText = "###### Mattermost Autolink Plugin Administration\n" +
 	"<linkref> is either the Name of a link, or its number in the `/autolink list` output. A partial Name can be specified, but some commands require it to be uniquely resolved.\n" +
+	"Arguments are separated by spaces. Quote an argument that contains spaces, e.g. `\"My link\"`: text in single quotes is taken as is, in double quotes a backslash escapes a double quote or a backslash, and elsewhere a backslash escapes a quote, a backslash or a space. Other backslashes are kept, e.g. in `\\d`. Values, such as the value of `set` and the text of `test`, are the rest of the command as it is, and `\"\"` is an empty value.\n" +
+	"Team and channel admins can manage the links whose Scope is limited to the teams and channels they administer. Links they add are scoped to the current team or channel.\n" +
 	"* `/autolink add <name>` - add a new link, named <name>.\n" +
+	"* `/autolink add` - add a new link in a dialog, and test it on a sample text.\n" +
 	"* `/autolink delete <linkref>` - delete a link.\n" +
 	"* `/autolink disable <linkref>` - disable a link.\n" +
 	"* `/autolink enable <linkref>` - enable a link.\n" +
 	"* `/autolink list <linkref>` - list a specific link.\n" +
 	"* `/autolink list <field> value` - list links whose <field> contains value. Here <field> can be Template or Pattern\n" +
 	"* `/autolink list` - list all configured links.\n" +
-	"* `/autolink set <linkref> <field> value...` - sets a link's field to a value. The entire command line after <field> is used for the value, unescaped, leading/trailing whitespace trimmed.\n" +
+	"* `/autolink edit <linkref>` - edit a link in a dialog, and test it on a sample text.\n" +
+	"* `/autolink set <linkref> <field> value...` - sets a link's field to a value. The rest of the command line after <field> is used for the value, unquoted, leading/trailing whitespace trimmed.\n" +
 	"* `/autolink test <linkref> test-text...` - test a link on a sample.\n" +
+	"* `/autolink test --all test-text...` - apply all the enabled links to a sample as if you posted it in the current channel, and show each step.\n" +
+	"* `/autolink test-history <linkref> [~channel] [count]` - test a link on the last posts of a channel you can read, 100 by default, without changing them.\n" +
+	"* `/autolink clone <linkref> <name>` - add a disabled copy of a link, named <name>.\n" +
+	"* `/autolink rename <linkref> <name>` - rename a link.\n" +
+	"* `/autolink move <linkref> <number>` - move a link to position <number>. Links are applied in the order of `/autolink list`.\n" +
+	"* `/autolink enable|disable|delete <selector>`, `/autolink set <selector> <field> value...` - change several links at once, after confirming the list of links in a dialog. <selector> is `name:JIRA-*`, `scope:team` or `scope:team/channel`, `pattern:value`, `template:value`, or a range of link numbers such as `3-9`. `/autolink list <selector>` lists the selected links.\n" +
+	"* `/autolink preset list` - list the presets, ready-made links for common services such as Jira or GitHub, and masking rules.\n" +
+	"* `/autolink preset add <preset> key=value...` - add a link from a preset and its parameters, e.g. `/autolink preset add github org=mattermost`. Set `name=value` to name the link.\n" +
+	"* `/autolink var list` - list the variables that templates can use as `${var.<name>}`, and the links that use them.\n" +
+	"* `/autolink var set <name> value...` - set a variable, and change all the links that use it. The rest of the command line after <name> is used for the value, unquoted.\n" +
+	"* `/autolink var delete <name>` - delete a variable that no link uses.\n" +
+	"* `/autolink group list` - list the link groups, their settings and their links.\n" +
+	"* `/autolink group add <name>` - add a link group. Add links to it with `/autolink set <linkref> Group <name>`.\n" +
+	"* `/autolink group set <name> <field> value...` - set the Scope or ProcessBotPosts of a group. Its links inherit them, unless they have a Scope of their own or set ProcessBotPosts themselves, set it to `\"\"` to inherit it again.\n" +
+	"* `/autolink group enable|disable <name>` - enable or disable all the links of a group at once.\n" +
+	"* `/autolink group delete <name>` - delete a group that has no links.\n" +
+	"* `/autolink mine list` - list the links you can turn off for your own posts.\n" +
+	"* `/autolink mine disable <name>` - stop applying a link marked UserOptional to your own posts.\n" +
+	"* `/autolink mine enable <name>` - apply a link marked UserOptional to your own posts again.\n" +
+	"* `/autolink revert <post-id>` - restore the original text of a post changed by the plugin, and stop processing it on future edits. Can be used by the author of the post.\n" +
+	"* `/autolink audit [count]` - list the most recent changes of links and denied attempts, 20 by default.\n" +
 	"\n" +
 	"Example:\n" +
 	"```\n" +
@@ -67,11 +90,98 @@ var autolinkCommandHandler = CommandHandler{
 		"enable":  executeEnable,
 		"add":     executeAdd,
 		"set":     executeSet,
+		"edit":    executeEdit,
+		"clone":   executeClone,
+		"rename":  executeRename,
+		"move":    executeMove,
 		"test":    executeTest,
+		"revert":  executeRevert,
+		"audit":   executeAudit,
+
+		"test-history": executeTestHistory,
+
+		"mine/list":    executeMineList,
+		"mine/enable":  executeMineEnable,
+		"mine/disable": executeMineDisable,
+
+		"preset/list": executePresetList,
+		"preset/add":  executePresetAdd,
+
+		"var/list":   executeVarList,
+		"var/set":    executeVarSet,
+		"var/delete": executeVarDelete,
+
+		"group/list":    executeGroupList,
+		"group/add":     executeGroupAdd,
+		"group/set":     executeGroupSet,
+		"group/enable":  executeGroupEnable,
+		"group/disable": executeGroupDisable,
+		"group/delete":  executeGroupDelete,
 	},
 	defaultHandler: executeHelp,
 }
 
+// commandPermissions is the permission level required to run each
+// subcommand. Subcommands that are not listed require permissionView.
+// Handlers of subcommands that anyone may run are responsible for their own
+// authorization.
+var commandPermissions = map[string]permissionLevel{
+	"revert":  permissionNone,
+	"mine":    permissionNone,
+	"list":    permissionView,
+	"test":    permissionView,
+	"add":     permissionEdit,
+	"set":     permissionEdit,
+	"edit":    permissionEdit,
+	"clone":   permissionEdit,
+	"rename":  permissionEdit,
+	"move":    permissionEdit,
+	"enable":  permissionEdit,
+	"disable": permissionEdit,
+	"preset":  permissionEdit,
+	"var":     permissionEdit,
+	"group":   permissionEdit,
+	"delete":  permissionOwn,
+	"audit":   permissionOwn,
+
+	"test-history": permissionEdit,
+}
+
+// subcommands returns the sorted names of the subcommands, for the
+// autocomplete description of the command.
+func subcommands() []string {
+	names := []string{"help"}
+	for name := range commandPermissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// subcommandsDesc describes the command in its autocomplete.
+func subcommandsDesc() string {
+	return "Available commands: " + strings.Join(subcommands(), ", ")
+}
+
+// delegatedCommands are the subcommands that team and channel admins may run
+// from their team or channel. Their handlers only let them manage links
+// scoped to the teams and channels they administer.
+var delegatedCommands = map[string]bool{
+	"list":    true,
+	"test":    true,
+	"add":     true,
+	"set":     true,
+	"edit":    true,
+	"clone":   true,
+	"rename":  true,
+	"enable":  true,
+	"disable": true,
+	"delete":  true,
+	"preset":  true,
+
+	"test-history": true,
+}
+
 func (ch CommandHandler) Handle(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
 	for n := len(args); n > 0; n-- {
 		h := ch.handlers[strings.Join(args[:n], "/")]
@@ -83,15 +193,43 @@ func (ch CommandHandler) Handle(p *Plugin, c *plugin.Context, header *model.Comm
 }
 
 func (p *Plugin) ExecuteCommand(c *plugin.Context, commandArgs *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
-	isAdmin, err := p.IsAuthorizedAdmin(commandArgs.UserId)
+	parsed, err := parseCommand(commandArgs.Command)
 	if err != nil {
-		return responsef("error occurred while authorizing the command: %v", err), nil
+		return responsef("%v", err), nil
+	}
+	args := argValues(parsed)
+
+	subcommand := ""
+	if len(args) >= 2 {
+		subcommand = args[1]
+	}
+	required, ok := commandPermissions[subcommand]
+	if !ok {
+		required = permissionView
 	}
-	if !isAdmin {
-		return responsef("`/autolink` commands can only be executed by a system administrator or `autolink` plugin admins."), nil
+
+	if required > permissionNone {
+		level, err := p.getPermissionLevel(commandArgs.UserId)
+		if err != nil {
+			return responsef("error occurred while authorizing the command: %v", err), nil
+		}
+		isDelegated := level < required && delegatedCommands[subcommand] && p.isDelegatedAdmin(commandArgs)
+		if level < required && !isDelegated {
+			p.audit(AuditEvent{
+				Source: auditSourceCommand,
+				UserID: commandArgs.UserId,
+				Action: subcommand,
+				Result: auditResultDenied,
+			})
+		}
+		if level == permissionNone && !isDelegated {
+			return responsef("`/autolink` commands can only be executed by a system administrator or `autolink` plugin admins."), nil
+		}
+		if level < required && !isDelegated {
+			return responsef("You do not have permission to run `/autolink %s`.", subcommand), nil
+		}
 	}
 
-	args := strings.Fields(commandArgs.Command)
 	if len(args) == 0 || args[0] != autolinkCommand {
 		return responsef(helpText), nil
 	}
@@ -104,35 +242,61 @@ func executeList(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args .
 	var refs []int
 	var err error
 
-	if len(args) > 0 && (args[0] == optTemplate || args[0] == optPattern) {
-		links, refs, err = searchLinkRefByTemplateOrPattern(p, header, args...)
-	} else {
+	switch {
+	case len(args) == 1 && (args[0] == optTemplate || args[0] == optPattern):
+		links = currentLinks(p)
+	case len(args) > 1 && (args[0] == optTemplate || args[0] == optPattern):
+		links, refs, err = searchLinkRefByTemplateOrPattern(p, args[0], restOfCommand(header.Command, len(args)-1))
+	case len(args) == 1 && isLinkSelector(args[0]):
+		links, refs, err = searchLinkSelector(p, args[0])
+	default:
 		links, refs, err = searchLinkRef(p, false, args...)
 	}
 	if err != nil {
 		return responsef("%v", err)
 	}
 
-	text := ""
-	if len(refs) > 0 {
-		for _, i := range refs {
-			text += links[i].ToMarkdown(i + 1)
+	if len(refs) == 0 {
+		for i := range links {
+			refs = append(refs, i)
 		}
-	} else {
-		if len(links) == 0 {
-			text += "There are no links to list"
+	}
+
+	level, err := p.getPermissionLevel(header.UserId)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	hideTemplates := level == permissionView && p.getConfig().HideTemplatesFromViewers
+
+	text := ""
+	for _, i := range refs {
+		l := links[i]
+		if level == permissionNone {
+			// Team and channel admins only see the links they can manage
+			canManage, scopeErr := p.canManageScope(header.UserId, l.Scope)
+			if scopeErr != nil || !canManage {
+				continue
+			}
 		}
-		for i, l := range links {
-			text += l.ToMarkdown(i + 1)
+		if hideTemplates {
+			l.Pattern = hiddenValue
+			l.Template = hiddenValue
 		}
+		text += l.ToMarkdown(i + 1)
+	}
+	if text == "" {
+		text = "There are no links to list"
 	}
 	return responsef(text)
 }
 
-func executeDelete(p *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...string) *model.CommandResponse {
+func executeDelete(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
 	if len(args) != 1 {
 		return responsef(helpText)
 	}
+	if isLinkSelector(args[0]) {
+		return p.confirmBulkChange(header, args[0], bulkChange{Action: "delete"})
+	}
 	oldLinks, refs, err := searchLinkRef(p, true, args...)
 	if err != nil {
 		return responsef("%v", err)
@@ -140,6 +304,10 @@ func executeDelete(p *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...s
 	n := refs[0]
 
 	removed := oldLinks[n]
+	if err = authorizeLinkChange(p, header, "delete", removed); err != nil {
+		return responsef("%v", err)
+	}
+
 	newLinks := oldLinks[:n]
 	if n+1 < len(oldLinks) {
 		newLinks = append(newLinks, oldLinks[n+1:]...)
@@ -149,6 +317,7 @@ func executeDelete(p *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...s
 	if err != nil {
 		return responsef(err.Error())
 	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "delete", &removed, nil, auditResultSuccess)
 
 	return responsef("removed: \n%v", removed.ToMarkdown(0))
 }
@@ -157,67 +326,53 @@ func executeSet(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ..
 	if len(args) < 3 {
 		return responsef(helpText)
 	}
+	if isLinkSelector(args[0]) {
+		return p.confirmBulkChange(header, args[0], bulkChange{
+			Action: "set",
+			Field:  args[1],
+			Value:  restOfCommand(header.Command, len(args)-2),
+			Args:   args[2:],
+		})
+	}
 
 	links, refs, err := searchLinkRef(p, true, args...)
 	if err != nil {
 		return responsef("%v", err)
 	}
-	l := &links[refs[0]]
+	// Change a copy, so that nothing is changed if the result is not authorized
+	before := links[refs[0]]
+	changed := before
+	l := &changed
+	if err = authorizeLinkChange(p, header, "set", *l); err != nil {
+		return responsef("%v", err)
+	}
 
 	fieldName := args[1]
-	restOfCommand := header.Command[len(autolinkCommand):] // "/autolink "
-	restOfCommand = restOfCommand[strings.Index(restOfCommand, args[0])+len(args[0]):]
-	restOfCommand = restOfCommand[strings.Index(restOfCommand, args[1])+len(args[1]):]
-	value := strings.TrimSpace(restOfCommand)
-
-	switch fieldName {
-	case optName:
-		l.Name = value
-	case optPattern:
-		l.Pattern = value
-	case optTemplate:
-		l.Template = value
-	case optScope:
-		l.Scope = args[2:]
-	case optDisableNonWordPrefix:
-		boolValue, e := parseBoolArg(value)
-		if e != nil {
-			return responsef("%v", e)
-		}
-		l.DisableNonWordPrefix = boolValue
-	case optDisableNonWordSuffix:
-		boolValue, e := parseBoolArg(value)
-		if e != nil {
-			return responsef("%v", e)
-		}
-		l.DisableNonWordSuffix = boolValue
-	case optWordMatch:
-		boolValue, e := parseBoolArg(value)
-		if e != nil {
-			return responsef("%v", e)
-		}
-		l.WordMatch = boolValue
-	case optDisabled:
-		boolValue, e := parseBoolArg(value)
-		if e != nil {
-			return responsef("%v", e)
-		}
-		l.Disabled = boolValue
-	case optProcessBotPosts:
-		boolValue, e := parseBoolArg(value)
-		if e != nil {
-			return responsef("%v", e)
+	value := restOfCommand(header.Command, len(args)-2)
+
+	if err = setLinkField(l, fieldName, value, args[2:]); err != nil {
+		return responsef("%v", err)
+	}
+	if l.Name != before.Name {
+		if err = checkLinkName(links, refs[0], l.Name); err != nil {
+			return responsef("%v", err)
 		}
-		l.ProcessBotPosts = boolValue
-	default:
-		return responsef("%q is not a supported field, must be one of %q", fieldName,
-			[]string{optName, optDisabled, optPattern, optTemplate, optScope, optDisableNonWordPrefix, optDisableNonWordSuffix, optWordMatch, optProcessBotPosts})
+	}
+	if err = p.getConfig().checkLinkGroup(*l); err != nil {
+		return responsef("%v", err)
 	}
 
+	if err = authorizeLinkChange(p, header, "set", *l); err != nil {
+		return responsef("%v", err)
+	}
+	links[refs[0]] = changed
+
 	err = saveConfigLinks(p, links)
 	if err != nil {
 		return responsef(err.Error())
 	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "set", &before, l, auditResultSuccess)
+	p.renameUserPreferences(before.Name, l.Name)
 
 	ref := args[0]
 	if l.Name != "" {
@@ -230,34 +385,56 @@ func executeTest(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args .
 	if len(args) < 2 {
 		return responsef(helpText)
 	}
+	if args[0] == optAll {
+		return executeTestAll(p, header, restOfCommand(header.Command, len(args)-1))
+	}
 
 	links, refs, err := searchLinkRef(p, false, args...)
 	if err != nil {
 		return responsef("%v", err)
 	}
 
-	restOfCommand := header.Command[len(autolinkCommand):] // "/autolink "
-	restOfCommand = restOfCommand[strings.Index(restOfCommand, args[0])+len(args[0]):]
-	orig := strings.TrimSpace(restOfCommand)
+	level, err := p.getPermissionLevel(header.UserId)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	conf := p.getConfig()
+	hidePatterns := level == permissionView && conf.HideTemplatesFromViewers
+
+	orig := restOfCommand(header.Command, len(args)-1)
 	out := fmt.Sprintf("- Original: `%s`\n", orig)
 
 	for _, ref := range refs {
 		l := links[ref]
-		l.Disabled = false
-		err = l.Compile()
+		err = conf.compileTestLink(&l)
 		if err != nil {
 			return responsef("failed to compile link %s: %v", l.DisplayName(), err)
 		}
-		replaced := l.Replace(orig)
-		if replaced == orig {
-			out += fmt.Sprintf("- Link %s: _no change_\n", l.DisplayName())
+		replaced := orig
+		if l.Action == autolink.ActionReject {
+			if reason, rejected := l.Rejection(orig); rejected {
+				out += fmt.Sprintf("- Link %s: rejects the post with `%s`\n", l.DisplayName(), reason)
+			} else {
+				out += fmt.Sprintf("- Link %s: _no change_\n", l.DisplayName())
+			}
 		} else {
-			out += fmt.Sprintf("- Link %s: changed to `%s`\n", l.DisplayName(), replaced)
-			orig = replaced
+			replaced = l.Replace(orig)
+			if replaced == orig {
+				out += fmt.Sprintf("- Link %s: _no change_\n", l.DisplayName())
+			} else {
+				out += fmt.Sprintf("- Link %s: changed to `%s`\n", l.DisplayName(), replaced)
+			}
+		}
+		if !hidePatterns {
+			out += fmt.Sprintf("  - Effective pattern: `%s`\n", l.EffectivePattern())
+		}
+		for _, m := range l.Matches(orig) {
+			out += fmt.Sprintf("  - Match %s\n", formatMatch(m))
 		}
+		orig = replaced
 	}
 
-	return responsef(out)
+	return responsef("%s", out)
 }
 
 func executeEnable(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
@@ -275,17 +452,31 @@ func executeDisable(p *Plugin, c *plugin.Context, header *model.CommandArgs, arg
 }
 
 func executeEnableImpl(p *Plugin, c *plugin.Context, header *model.CommandArgs, ref string, enabled bool) *model.CommandResponse {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	if isLinkSelector(ref) {
+		return p.confirmBulkChange(header, ref, bulkChange{Action: action})
+	}
+
 	links, refs, err := searchLinkRef(p, true, ref)
 	if err != nil {
 		return responsef("%v", err)
 	}
+
 	l := &links[refs[0]]
+	if err = authorizeLinkChange(p, header, action, *l); err != nil {
+		return responsef("%v", err)
+	}
+	before := *l
 	l.Disabled = !enabled
 
 	err = saveConfigLinks(p, links)
 	if err != nil {
 		return responsef(err.Error())
 	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", action, &before, l, auditResultSuccess)
 
 	if l.Name != "" {
 		ref = l.Name
@@ -297,24 +488,56 @@ func executeAdd(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ..
 	if len(args) > 1 {
 		return responsef(helpText)
 	}
-	name := ""
-	if len(args) == 1 {
-		name = args[0]
+	if len(args) == 0 {
+		if err := p.openLinkDialog(header.TriggerId, nil); err != nil {
+			return responsef("failed to open the dialog: %v", err)
+		}
+		return &model.CommandResponse{}
+	}
+	name := args[0]
+
+	level, err := p.getPermissionLevel(header.UserId)
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	// Links added by team and channel admins start out limited to the
+	// current team or channel
+	var scope []string
+	if level < permissionEdit {
+		scope, err = p.delegatedScope(header)
+		if err != nil {
+			return responsef("%v", err)
+		}
 	}
 
-	err := saveConfigLinks(p, append(p.getConfig().Links, autolink.Autolink{
-		Name: name,
-	}))
+	added := autolink.Autolink{
+		Name:  name,
+		Scope: scope,
+		Owner: header.UserId,
+	}
+	err = saveConfigLinks(p, append(p.getConfig().Links, added))
 	if err != nil {
 		return responsef(err.Error())
 	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "add", nil, &added, auditResultSuccess)
 
-	if name == "" {
-		return executeList(p, c, header)
-	}
 	return executeList(p, c, header, name)
 }
 
+func executeRevert(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+
+	post, err := p.revertPost(header.UserId, parsePostID(args[0]))
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	return responsef("Reverted the post to its original text:\n```\n%s\n```", post.Message)
+}
+
 func executeHelp(_ *Plugin, _ *plugin.Context, _ *model.CommandArgs, _ ...string) *model.CommandResponse {
 	return responsef(helpText)
 }
@@ -327,8 +550,14 @@ func responsef(format string, args ...interface{}) *model.CommandResponse {
 	}
 }
 
+// currentLinks returns a copy of the links, in the order they are processed.
+// Link numbers are positions in this order.
+func currentLinks(p *Plugin) []autolink.Autolink {
+	return append([]autolink.Autolink{}, p.getConfig().Links...)
+}
+
 func searchLinkRef(p *Plugin, requireUnique bool, args ...string) ([]autolink.Autolink, []int, error) {
-	links := p.getConfig().Sorted().Links
+	links := currentLinks(p)
 	if len(args) == 0 {
 		if requireUnique {
 			return nil, nil, errors.New("unreachable")
@@ -346,14 +575,23 @@ func searchLinkRef(p *Plugin, requireUnique bool, args ...string) ([]autolink.Au
 	}
 
 	found := []int{}
+	exact := []int{}
 	for i, l := range links {
 		if strings.Contains(l.Name, args[0]) {
 			found = append(found, i)
 		}
+		if l.Name == args[0] {
+			exact = append(exact, i)
+		}
 	}
 	if len(found) == 0 {
 		return nil, nil, errors.Errorf("%q not found", args[0])
 	}
+	// A link whose name is part of another link's name can still be
+	// referred to by its full name, e.g. after it was cloned
+	if requireUnique && len(exact) == 1 {
+		return links, exact, nil
+	}
 	if requireUnique && len(found) > 1 {
 		names := []string{}
 		for _, i := range found {
@@ -365,20 +603,15 @@ func searchLinkRef(p *Plugin, requireUnique bool, args ...string) ([]autolink.Au
 	return links, found, nil
 }
 
-func searchLinkRefByTemplateOrPattern(p *Plugin, header *model.CommandArgs, args ...string) ([]autolink.Autolink, []int, error) {
-	links := p.getConfig().Sorted().Links
-	if len(args) == 1 {
-		return links, nil, nil
-	}
-
-	restOfCommand := header.Command[len(autolinkCommand):]
-	restOfCommand = restOfCommand[strings.Index(restOfCommand, args[0])+len(args[0]):]
-	value := strings.TrimSpace(restOfCommand)
+// searchLinkRefByTemplateOrPattern returns the links and the indexes of
+// those whose field, Template or Pattern, contains value.
+func searchLinkRefByTemplateOrPattern(p *Plugin, field, value string) ([]autolink.Autolink, []int, error) {
+	links := currentLinks(p)
 
 	found := []int{}
 	for i, l := range links {
-		if (args[0] == optTemplate && strings.Contains(strings.ToLower(l.Template), strings.ToLower(value))) ||
-			(args[0] == optPattern && strings.Contains(strings.ToLower(l.Pattern), strings.ToLower(value))) {
+		if (field == optTemplate && strings.Contains(strings.ToLower(l.Template), strings.ToLower(value))) ||
+			(field == optPattern && strings.Contains(strings.ToLower(l.Pattern), strings.ToLower(value))) {
 			found = append(found, i)
 		}
 	}
@@ -390,6 +623,37 @@ func searchLinkRefByTemplateOrPattern(p *Plugin, header *model.CommandArgs, args
 	return links, found, nil
 }
 
+// authorizeLinkChange returns an error if the user running the command may
+// not manage l, or may not delete it for the delete action. Denied actions
+// are audited.
+func authorizeLinkChange(p *Plugin, header *model.CommandArgs, action string, l autolink.Autolink) error {
+	return authorizeLinkChangeFrom(p, auditSourceCommand, header, action, l)
+}
+
+func authorizeLinkChangeFrom(p *Plugin, source string, header *model.CommandArgs, action string, l autolink.Autolink) error {
+	if action == "delete" {
+		canDelete, err := p.CanDeleteLink(header.UserId, l)
+		if err != nil {
+			return err
+		}
+		if !canDelete {
+			p.auditLinkChange(source, header.UserId, "", action, &l, &l, auditResultDenied)
+			return errors.Errorf("you can't delete %q, editors can't delete links, and team and channel admins only the links whose Scope is limited to the teams and channels they administer", l.DisplayName())
+		}
+		return nil
+	}
+
+	canManage, err := p.CanManageLink(header.UserId, l)
+	if err != nil {
+		return err
+	}
+	if !canManage {
+		p.auditLinkChange(source, header.UserId, "", action, &l, &l, auditResultDenied)
+		return errors.Errorf("you can only manage links whose Scope is limited to the teams and channels you administer, %q is not one of them", l.DisplayName())
+	}
+	return nil
+}
+
 func parseBoolArg(arg string) (bool, error) {
 	switch strings.ToLower(arg) {
 	case "true", "on":
@@ -10,22 +10,53 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/presets"
 )
 
 // Config from config.json
 type Config struct {
-	EnableAdminCommand bool                `json:"enableadmincommand"`
-	EnableOnUpdate     bool                `json:"enableonupdate"`
-	PluginAdmins       string              `json:"pluginadmins"`
-	Links              []autolink.Autolink `json:"links"`
+	EnableAdminCommand       bool                 `json:"enableadmincommand"`
+	EnableOnUpdate           bool                 `json:"enableonupdate"`
+	PluginAdmins             string               `json:"pluginadmins"`
+	ProcessingTimeBudget     int                  `json:"processingtimebudget"`
+	OptOutDirective          string               `json:"optoutdirective"`
+	EnableEscapePrefix       bool                 `json:"enableescapeprefix"`
+	Viewers                  string               `json:"viewers"`
+	Editors                  string               `json:"editors"`
+	AllowedPlugins           string               `json:"allowedplugins"`
+	AllowAnyPlugin           bool                 `json:"allowanyplugin"`
+	HideTemplatesFromViewers bool                 `json:"hidetemplatesfromviewers"`
+	NotificationChannel      string               `json:"notificationchannel"`
+	Links                    []autolink.Autolink  `json:"links"`
+	Groups                   []autolink.LinkGroup `json:"groups"`
+
+	// Variables are the values templates reference as ${var.NAME}, e.g. a
+	// base URL shared by several links.
+	Variables map[string]string `json:"variables"`
 
 	// AdminUserIds is a set of UserIds that are permitted to perform
 	// administrative operations on the plugin configuration (i.e. plugin
 	// admins). On each configuration change the contents of PluginAdmins
 	// config field is parsed into this field.
 	AdminUserIds map[string]struct{} `json:"-"`
+
+	// EditorUserIds is a set of UserIds that are permitted to add, change,
+	// enable and disable links, but not to delete them. It is parsed from the
+	// Editors config field.
+	EditorUserIds map[string]struct{} `json:"-"`
+
+	// AllowedPluginIds is a set of IDs of the plugins permitted to use the
+	// REST API. It is parsed from the AllowedPlugins config field.
+	AllowedPluginIds map[string]struct{} `json:"-"`
 }
 
+const (
+	// viewersAdmins only lets plugin admins and editors list and test links.
+	viewersAdmins = "admins"
+	// viewersEveryone lets every user list and test links.
+	viewersEveryone = "everyone"
+)
+
 // OnConfigurationChange is invoked when configuration changes may have been made.
 func (p *Plugin) OnConfigurationChange() error {
 	var c Config
@@ -33,9 +64,16 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "failed to load plugin configuration")
 	}
 
+	failed := []autolink.Autolink{}
+	compileErrs := []error{}
 	for i := range c.Links {
-		if err := c.Links[i].Compile(); err != nil {
+		if err := c.compileLink(&c.Links[i]); err != nil {
 			p.API.LogError("Error creating autolinker", "link", c.Links[i], "error", err.Error())
+			failed = append(failed, c.Links[i])
+			compileErrs = append(compileErrs, err)
+		}
+		if err := c.Links[i].CheckUserOptional(); err != nil {
+			p.API.LogWarn("UserOptional is ignored", "link", c.Links[i].DisplayName(), "error", err.Error())
 		}
 	}
 
@@ -43,11 +81,43 @@ func (p *Plugin) OnConfigurationChange() error {
 	// not fatal, if everything fails only sysadmin will be able to manage the
 	// config which is still OK
 	c.parsePluginAdminList(p.API)
+	c.parseEditorList(p.API)
+	c.parseAllowedPluginList()
 
+	var oldLinks []autolink.Autolink
+	var oldFailures map[string]string
+	loaded := false
 	p.UpdateConfig(func(conf *Config) {
+		oldLinks, oldFailures, loaded = conf.Links, p.compileFailures, p.confLoaded
 		*conf = c
+		p.confLoaded = true
+	})
+
+	// Changes made with commands or the API have already been applied to
+	// the current configuration, so the remaining differences come from
+	// editing the configuration directly.
+	if loaded {
+		p.auditConfigChange(oldLinks, c.Links)
+	}
+
+	// Owners are not told again about the links that already failed to
+	// compile when the plugin started.
+	failures := p.notifyCompileFailures(failed, compileErrs, oldFailures, loaded)
+	p.UpdateConfig(func(*Config) {
+		p.compileFailures = failures
 	})
 
+	// The evaluation of the links is observed by name, the names of the
+	// links that are gone are dropped from the metrics.
+	names := make([]string, 0, len(c.Links))
+	for _, l := range c.Links {
+		names = append(names, l.DisplayName())
+	}
+	p.metrics.SetLinks(names)
+
+	p.updateDigestJob(c.NotificationChannel != "")
+	p.updateExpiryJob(hasExpiringLinks(c.Links))
+
 	go func() {
 		if c.EnableAdminCommand {
 			_ = p.API.RegisterCommand(&model.Command{
@@ -55,7 +125,7 @@ func (p *Plugin) OnConfigurationChange() error {
 				DisplayName:      "Autolink",
 				Description:      "Autolink administration.",
 				AutoComplete:     true,
-				AutoCompleteDesc: "Available commands: add, delete, disable, enable, list, set, test",
+				AutoCompleteDesc: subcommandsDesc(),
 				AutoCompleteHint: "[command]",
 				AutocompleteData: getAutoCompleteData(),
 			})
@@ -68,90 +138,178 @@ func (p *Plugin) OnConfigurationChange() error {
 }
 
 func getAutoCompleteData() *model.AutocompleteData {
-	autolink := model.NewAutocompleteData("autolink", "[command]",
-		"Available command : add, delete, disable, enable, list, set, test")
+	autolink := model.NewAutocompleteData("autolink", "[command]", subcommandsDesc())
 
 	add := model.NewAutocompleteData("add", "",
 		"Add a new link with a given name")
-	add.AddTextArgument("Name for a new link", "[name]", "")
+	add.AddTextArgument("Name for a new link, leave empty to add it in a dialog", "[name]", "")
 	autolink.AddCommand(add)
 
 	deleteLink := model.NewAutocompleteData("delete", "",
 		"Delete a link with a given name")
-	deleteLink.AddTextArgument("Name of the link to delete", "[name]", "")
+	deleteLink.AddDynamicListArgument("Name of the link to delete", autocompleteURL+autocompleteLinks, true)
 	autolink.AddCommand(deleteLink)
 
+	clone := model.NewAutocompleteData("clone", "",
+		"Add a disabled copy of a link")
+	clone.AddDynamicListArgument("Name of the link to copy", autocompleteURL+autocompleteLinks, true)
+	clone.AddTextArgument("Name of the new link", "[name]", "")
+	autolink.AddCommand(clone)
+
+	rename := model.NewAutocompleteData("rename", "",
+		"Rename a link")
+	rename.AddDynamicListArgument("Name of the link to rename", autocompleteURL+autocompleteLinks, true)
+	rename.AddTextArgument("New name of the link", "[name]", "")
+	autolink.AddCommand(rename)
+
+	move := model.NewAutocompleteData("move", "",
+		"Move a link in the order links are applied")
+	move.AddDynamicListArgument("Name of the link to move", autocompleteURL+autocompleteLinks, true)
+	move.AddTextArgument("New position of the link, as numbered in the list", "[number]", "")
+	autolink.AddCommand(move)
+
+	edit := model.NewAutocompleteData("edit", "",
+		"Edit a link with a given name in a dialog")
+	edit.AddDynamicListArgument("Name of the link to edit", autocompleteURL+autocompleteLinks, true)
+	autolink.AddCommand(edit)
+
 	disable := model.NewAutocompleteData("disable", "",
 		"Disable a link with a given name")
-	disable.AddTextArgument("Name of the link to disable", "[name]", "")
+	disable.AddDynamicListArgument("Name of the link to disable", autocompleteURL+autocompleteLinks, true)
 	autolink.AddCommand(disable)
 
 	enable := model.NewAutocompleteData("enable", "",
 		"Enable a link with a given name")
-	enable.AddTextArgument("Name of the link to enable", "[name]", "")
+	enable.AddDynamicListArgument("Name of the link to enable", autocompleteURL+autocompleteLinks, true)
 	autolink.AddCommand(enable)
 
 	list := model.NewAutocompleteData("list", "",
 		"List all configured links")
-	list.AddStaticListArgument("List the link which match with the given condition",
-		false, []model.AutocompleteListItem{
-			{
-				HelpText: "If `name` of a link is provided, it will only list a configuration of `name` link ",
-				Hint:     "(optional)",
-				Item:     "[name]",
-			},
-			{
-				HelpText: "List configuration of link matched with the given template",
-				Hint:     "(optional)",
-				Item:     "Template",
-			},
-			{
-				HelpText: "List configuration of link matched with the given pattern",
-				Hint:     "(optional)",
-				Item:     "Pattern",
-			},
-		})
+	list.AddDynamicListArgument("List the links which match with the given name, template or pattern",
+		autocompleteURL+autocompleteList, false)
 	autolink.AddCommand(list)
 
+	fields := []model.AutocompleteListItem{}
+	for _, field := range settableFields() {
+		helpText, ok := fieldHelpTexts[field]
+		if !ok {
+			helpText = "Set the `" + field + "` field"
+		}
+		fields = append(fields, model.AutocompleteListItem{
+			HelpText: helpText,
+			Item:     field,
+		})
+	}
 	set := model.NewAutocompleteData("set", "",
 		"Set a field of a link with a given value")
-	set.AddTextArgument("Name of a link to set", "[name]", "")
-	set.AddStaticListArgument("A name of a field to set a value", false,
-		[]model.AutocompleteListItem{
-			{
-				HelpText: "Set the `Template` field",
-				Hint:     "",
-				Item:     "Template",
-			},
-			{
-				HelpText: "Set the `Pattern` field",
-				Hint:     "",
-				Item:     "Pattern",
-			},
-			{
-				HelpText: "If true uses the \\b word boundaries",
-				Hint:     "",
-				Item:     "WordMatch",
-			},
-			{
-				HelpText: "If true applies changes to posts created by bot accounts.",
-				Hint:     "",
-				Item:     "ProcessBotPosts",
-			},
-			{
-				HelpText: "team/channel the autolink applies to",
-				Hint:     "",
-				Item:     "Scope",
-			},
-		})
+	set.AddDynamicListArgument("Name of a link to set", autocompleteURL+autocompleteLinks, true)
+	set.AddStaticListArgument("A name of a field to set a value", true, fields)
 	autolink.AddCommand(set)
 
 	test := model.NewAutocompleteData("test", "",
 		"Test a link on the text provided")
-	test.AddTextArgument("Name of a link to test with", "[name]", "")
+	test.AddDynamicListArgument("Name of a link to test with, or --all to test all the links", autocompleteURL+autocompleteTest, true)
 	test.AddTextArgument("Sample text which the link applies", "[sample text]", "")
 	autolink.AddCommand(test)
 
+	testHistory := model.NewAutocompleteData("test-history", "",
+		"Test a link on the last posts of a channel, without changing them")
+	testHistory.AddDynamicListArgument("Name of a link to test with", autocompleteURL+autocompleteLinks, true)
+	testHistory.AddTextArgument("Channel to test the link on, the current channel by default", "[~channel]", "")
+	testHistory.AddTextArgument("Number of posts to test the link on", "[count]", "")
+	autolink.AddCommand(testHistory)
+
+	presetItems := []model.AutocompleteListItem{}
+	for _, preset := range presets.List() {
+		presetItems = append(presetItems, model.AutocompleteListItem{
+			HelpText: preset.Description,
+			Item:     preset.Name,
+		})
+	}
+	preset := model.NewAutocompleteData("preset", "[command]",
+		"Add links for common services from presets")
+	presetList := model.NewAutocompleteData("list", "",
+		"List the presets and their parameters")
+	preset.AddCommand(presetList)
+	presetAdd := model.NewAutocompleteData("add", "",
+		"Add a link from a preset")
+	presetAdd.AddStaticListArgument("Name of the preset", true, presetItems)
+	presetAdd.AddTextArgument("Parameters of the preset, see `/autolink preset list`", "[key=value...]", "")
+	preset.AddCommand(presetAdd)
+	autolink.AddCommand(preset)
+
+	variables := model.NewAutocompleteData("var", "[command]",
+		"Manage the variables templates use as ${var.NAME}")
+	varList := model.NewAutocompleteData("list", "",
+		"List the variables and the links that use them")
+	variables.AddCommand(varList)
+	varSet := model.NewAutocompleteData("set", "",
+		"Set a variable")
+	varSet.AddTextArgument("Name of the variable", "[name]", "")
+	varSet.AddTextArgument("Value of the variable", "[value]", "")
+	variables.AddCommand(varSet)
+	varDelete := model.NewAutocompleteData("delete", "",
+		"Delete a variable")
+	varDelete.AddTextArgument("Name of the variable", "[name]", "")
+	variables.AddCommand(varDelete)
+	autolink.AddCommand(variables)
+
+	group := model.NewAutocompleteData("group", "[command]",
+		"Manage the groups of links that share settings")
+	groupList := model.NewAutocompleteData("list", "",
+		"List the groups and their links")
+	group.AddCommand(groupList)
+	groupAdd := model.NewAutocompleteData("add", "",
+		"Add a group")
+	groupAdd.AddTextArgument("Name of the group", "[name]", "")
+	group.AddCommand(groupAdd)
+	groupSet := model.NewAutocompleteData("set", "",
+		"Set a setting the links of a group inherit")
+	groupSet.AddDynamicListArgument("Name of the group", autocompleteURL+autocompleteGroups, true)
+	groupSet.AddStaticListArgument("Name of the setting", true, []model.AutocompleteListItem{
+		{Item: "Scope", HelpText: fieldHelpTexts["Scope"]},
+		{Item: "ProcessBotPosts", HelpText: fieldHelpTexts["ProcessBotPosts"]},
+	})
+	group.AddCommand(groupSet)
+	groupEnable := model.NewAutocompleteData("enable", "",
+		"Enable all the links of a group")
+	groupEnable.AddDynamicListArgument("Name of the group", autocompleteURL+autocompleteGroups, true)
+	group.AddCommand(groupEnable)
+	groupDisable := model.NewAutocompleteData("disable", "",
+		"Disable all the links of a group")
+	groupDisable.AddDynamicListArgument("Name of the group", autocompleteURL+autocompleteGroups, true)
+	group.AddCommand(groupDisable)
+	groupDelete := model.NewAutocompleteData("delete", "",
+		"Delete a group that has no links")
+	groupDelete.AddDynamicListArgument("Name of the group", autocompleteURL+autocompleteGroups, true)
+	group.AddCommand(groupDelete)
+	autolink.AddCommand(group)
+
+	mine := model.NewAutocompleteData("mine", "[command]",
+		"Manage the links applied to your own posts")
+	mineList := model.NewAutocompleteData("list", "",
+		"List the links you can turn off for your own posts")
+	mine.AddCommand(mineList)
+	mineDisable := model.NewAutocompleteData("disable", "",
+		"Stop applying a link to your own posts")
+	mineDisable.AddDynamicListArgument("Name of the link to turn off", autocompleteURL+autocompleteOptional, true)
+	mine.AddCommand(mineDisable)
+	mineEnable := model.NewAutocompleteData("enable", "",
+		"Apply a link to your own posts again")
+	mineEnable.AddDynamicListArgument("Name of the link to turn on", autocompleteURL+autocompleteOptional, true)
+	mine.AddCommand(mineEnable)
+	autolink.AddCommand(mine)
+
+	revert := model.NewAutocompleteData("revert", "",
+		"Restore the original text of a post changed by the plugin")
+	revert.AddTextArgument("ID or permalink of the post to revert", "[post-id]", "")
+	autolink.AddCommand(revert)
+
+	audit := model.NewAutocompleteData("audit", "",
+		"List the most recent changes of links")
+	audit.AddTextArgument("Number of events to list", "[count]", "")
+	autolink.AddCommand(audit)
+
 	help := model.NewAutocompleteData("help", "", "Autolink plugin slash command help")
 	autolink.AddCommand(help)
 
@@ -213,34 +371,109 @@ func (conf *Config) ToMap() (map[string]interface{}, error) {
 	return out, nil
 }
 
-// Sorted returns a clone of the Config, with links sorted alphabetically
+// Sorted returns a clone of the Config, with links sorted alphabetically.
+// The links are processed in the order of conf.Links, which is the order
+// used by the /autolink command.
 func (conf *Config) Sorted() *Config {
-	sorted := conf
+	sorted := *conf
 	sorted.Links = append([]autolink.Autolink{}, conf.Links...)
-	sort.Slice(conf.Links, func(i, j int) bool {
-		return strings.Compare(conf.Links[i].DisplayName(), conf.Links[j].DisplayName()) < 0
+	sort.Slice(sorted.Links, func(i, j int) bool {
+		return strings.Compare(sorted.Links[i].DisplayName(), sorted.Links[j].DisplayName()) < 0
 	})
-	return conf
+	return &sorted
+}
+
+// Mandatory returns a clone of the Config with only the links that redact or
+// reject what they match, which are applied even to the posts opted out of
+// the other links.
+func (conf *Config) Mandatory() *Config {
+	mandatory := *conf
+	mandatory.Links = []autolink.Autolink{}
+	for _, l := range conf.Links {
+		if l.IsMandatory() {
+			mandatory.Links = append(mandatory.Links, l)
+		}
+	}
+	return &mandatory
+}
+
+// compileLink compiles l with the options that come from the configuration.
+func (conf *Config) compileLink(l *autolink.Autolink) error {
+	l.HonorEscape = conf.EnableEscapePrefix
+	l.Variables = conf.Variables
+	l.GroupDefaults = conf.group(l.Group)
+	return l.Compile()
+}
+
+// compileTestLink compiles l to be tested, even if it or its group is
+// disabled, or it is not active now. The settings l inherits from its group
+// are copied to it.
+func (conf *Config) compileTestLink(l *autolink.Autolink) error {
+	l.GroupDefaults = conf.group(l.Group)
+	*l = l.Effective()
+	l.Disabled = false
+	l.GroupDefaults = nil
+	l.ActiveFrom = nil
+	l.ActiveUntil = nil
+	l.HonorEscape = conf.EnableEscapePrefix
+	l.Variables = conf.Variables
+	return l.Compile()
+}
+
+// group returns the group named name, or nil if there is none.
+func (conf *Config) group(name string) *autolink.LinkGroup {
+	if name == "" {
+		return nil
+	}
+	for i := range conf.Groups {
+		if strings.EqualFold(conf.Groups[i].Name, name) {
+			return &conf.Groups[i]
+		}
+	}
+	return nil
 }
 
 // parsePluginAdminList parses the contents of PluginAdmins config field
 func (conf *Config) parsePluginAdminList(api plugin.API) {
-	conf.AdminUserIds = make(map[string]struct{}, len(conf.PluginAdmins))
+	conf.AdminUserIds = parseUserIDList(api, conf.PluginAdmins)
+}
 
-	if len(conf.PluginAdmins) == 0 {
-		// There were no plugin admin users defined
-		return
+// parseEditorList parses the contents of Editors config field
+func (conf *Config) parseEditorList(api plugin.API) {
+	conf.EditorUserIds = parseUserIDList(api, conf.Editors)
+}
+
+// parseAllowedPluginList parses the contents of AllowedPlugins config field
+func (conf *Config) parseAllowedPluginList() {
+	conf.AllowedPluginIds = make(map[string]struct{})
+	for _, pluginID := range strings.Split(conf.AllowedPlugins, ",") {
+		pluginID = strings.TrimSpace(pluginID)
+		if pluginID != "" {
+			conf.AllowedPluginIds[pluginID] = struct{}{}
+		}
 	}
+}
+
+// parseUserIDList parses a comma-separated list of user IDs, skipping the
+// users that do not exist.
+func parseUserIDList(api plugin.API, list string) map[string]struct{} {
+	userIDs := make(map[string]struct{})
 
-	userIDs := strings.Split(conf.PluginAdmins, ",")
-	for _, userID := range userIDs {
+	if len(list) == 0 {
+		// There were no users defined
+		return userIDs
+	}
+
+	for _, userID := range strings.Split(list, ",") {
 		userID = strings.TrimSpace(userID)
 		// Let's verify that the given user really exists
 		_, appErr := api.GetUser(userID)
 		if appErr != nil {
 			api.LogWarn("Error occurred while verifying userID", "userID", userID, "error", appErr)
 		} else {
-			conf.AdminUserIds[userID] = struct{}{}
+			userIDs[userID] = struct{}{}
 		}
 	}
+
+	return userIDs
 }
@@ -62,3 +62,17 @@ func TestOnConfigurationChange(t *testing.T) {
 		api.AssertNumberOfCalls(t, "LogError", 1)
 	})
 }
+
+func TestSorted(t *testing.T) {
+	conf := &Config{
+		Links: []autolink.Autolink{{Name: "b"}, {Name: "c"}, {Name: "a"}},
+	}
+
+	sorted := conf.Sorted()
+	assert.Equal(t, "a", sorted.Links[0].Name)
+	assert.Equal(t, "b", sorted.Links[1].Name)
+	assert.Equal(t, "c", sorted.Links[2].Name)
+	assert.Equal(t, "b", conf.Links[0].Name)
+	assert.Equal(t, "c", conf.Links[1].Name)
+	assert.Equal(t, "a", conf.Links[2].Name)
+}
@@ -0,0 +1,426 @@
+package autolinkplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const linkDialogURL = "/plugins/mattermost-autolink/api/v1/dialog"
+
+const (
+	dialogCallbackAdd  = "add"
+	dialogCallbackEdit = "edit"
+)
+
+// Names of the elements of the link dialog.
+const (
+	dialogName                 = "name"
+	dialogPattern              = "pattern"
+	dialogTemplate             = "template"
+	dialogScope                = "scope"
+	dialogWordMatch            = "word_match"
+	dialogDisableNonWordPrefix = "disable_non_word_prefix"
+	dialogDisableNonWordSuffix = "disable_non_word_suffix"
+	dialogProcessBotPosts      = "process_bot_posts"
+	dialogUserOptional         = "user_optional"
+	dialogDisabled             = "disabled"
+	dialogAction               = "action"
+	dialogAuditRedactions      = "audit_redactions"
+	dialogGroup                = "group"
+	dialogActiveFrom           = "active_from"
+	dialogActiveUntil          = "active_until"
+	dialogSample               = "sample"
+	dialogPreview              = "preview"
+)
+
+// linkDialogState is passed through the dialog, to find the edited link
+// when the dialog is submitted.
+type linkDialogState struct {
+	Before *autolink.Autolink `json:"before,omitempty"`
+}
+
+// openLinkDialog opens the dialog to edit link, or to add a new link if link
+// is nil.
+func (p *Plugin) openLinkDialog(triggerID string, link *autolink.Autolink) error {
+	callbackID := dialogCallbackAdd
+	// The title is limited to model.DialogTitleMaxLength, the name of the
+	// link is in the introduction
+	title := "Add a link"
+	intro := ""
+	submitLabel := "Add"
+	l := autolink.Autolink{}
+	if link != nil {
+		callbackID = dialogCallbackEdit
+		title = "Edit link"
+		intro = fmt.Sprintf("Editing link **%s**. ", link.DisplayName())
+		submitLabel = "Save"
+		l = *link
+	}
+
+	processBotPostsDefault := ""
+	if l.ProcessBotPosts != nil {
+		processBotPostsDefault = strconv.FormatBool(*l.ProcessBotPosts)
+	}
+
+	state, err := json.Marshal(linkDialogState{Before: link})
+	if err != nil {
+		return err
+	}
+
+	timeElement := func(name, displayName, helpText string, value *time.Time) model.DialogElement {
+		e := model.DialogElement{
+			DisplayName: displayName,
+			Name:        name,
+			Type:        "text",
+			Placeholder: "2024-06-01T09:00:00Z",
+			HelpText:    helpText,
+			Optional:    true,
+		}
+		if value != nil {
+			e.Default = value.Format(time.RFC3339)
+		}
+		return e
+	}
+
+	boolElement := func(name, displayName, helpText string, value bool) model.DialogElement {
+		return model.DialogElement{
+			DisplayName: displayName,
+			Name:        name,
+			Type:        "bool",
+			Default:     fmt.Sprintf("%v", value),
+			HelpText:    helpText,
+			Optional:    true,
+		}
+	}
+
+	dialog := model.Dialog{
+		CallbackId:       callbackID,
+		Title:            title,
+		IntroductionText: intro + "The pattern is validated when the dialog is submitted. Check **Preview** to test the link on the sample text without saving it.",
+		SubmitLabel:      submitLabel,
+		State:            string(state),
+		Elements: []model.DialogElement{{
+			DisplayName: "Name",
+			Name:        dialogName,
+			Type:        "text",
+			Default:     l.Name,
+			Optional:    true,
+		}, {
+			DisplayName: "Pattern",
+			Name:        dialogPattern,
+			Type:        "textarea",
+			Default:     l.Pattern,
+			HelpText:    "Regular expression, named groups can be used in the template.",
+			MaxLength:   3000,
+		}, {
+			DisplayName: "Template",
+			Name:        dialogTemplate,
+			Type:        "textarea",
+			Default:     l.Template,
+			HelpText:    "Replacement text, e.g. [$key](https://example.com/$key).",
+			MaxLength:   3000,
+		}, {
+			DisplayName: "Scope",
+			Name:        dialogScope,
+			Type:        "text",
+			Default:     strings.Join(l.Scope, " "),
+			Placeholder: "team team/channel",
+			HelpText:    "Space-separated teams and team/channel names the link is limited to. Leave empty to apply it everywhere.",
+			Optional:    true,
+		},
+			boolElement(dialogWordMatch, "WordMatch", "Use \\b word boundaries.", l.WordMatch),
+			boolElement(dialogDisableNonWordPrefix, "DisableNonWordPrefix", "Match even when not preceded by whitespace.", l.DisableNonWordPrefix),
+			boolElement(dialogDisableNonWordSuffix, "DisableNonWordSuffix", "Match even when not followed by whitespace or punctuation.", l.DisableNonWordSuffix),
+			{
+				DisplayName: "ProcessBotPosts",
+				Name:        dialogProcessBotPosts,
+				Type:        "select",
+				Default:     processBotPostsDefault,
+				HelpText:    "Apply the link to posts made by bot accounts. Leave empty to use the setting of the group.",
+				Optional:    true,
+				Options: []*model.PostActionOptions{
+					{Text: "true", Value: "true"},
+					{Text: "false", Value: "false"},
+				},
+			},
+			boolElement(dialogUserOptional, "UserOptional", "Let users turn the link off for their own posts. Links that redact or reject can't be turned off.", l.UserOptional),
+			boolElement(dialogDisabled, "Disabled", "Keep the link without applying it.", l.Disabled),
+			{
+				DisplayName: "Action",
+				Name:        dialogAction,
+				Type:        "select",
+				Default:     l.Action,
+				HelpText:    "What the link does to the posts it matches. Leave empty to replace the matches with the template.",
+				Optional:    true,
+				Options: []*model.PostActionOptions{
+					{Text: "Redact the matches and warn the author", Value: autolink.ActionRedact},
+					{Text: "Reject the post with the template as the reason", Value: autolink.ActionReject},
+				},
+			},
+			boolElement(dialogAuditRedactions, "AuditRedactions", "Record the posts the link redacts in the audit log.", l.AuditRedactions),
+			{
+				DisplayName: "Group",
+				Name:        dialogGroup,
+				Type:        "text",
+				Default:     l.Group,
+				HelpText:    "Link group whose settings the link inherits, see `/autolink group list`.",
+				Optional:    true,
+			},
+			timeElement(dialogActiveFrom, "ActiveFrom", "Time the link is applied from, leave empty to apply it now.", l.ActiveFrom),
+			timeElement(dialogActiveUntil, "ActiveUntil", "Time the link is no longer applied, leave empty to apply it indefinitely.", l.ActiveUntil),
+			{
+				DisplayName: "Sample text",
+				Name:        dialogSample,
+				Type:        "textarea",
+				HelpText:    "Text to test the link on.",
+				Optional:    true,
+				MaxLength:   3000,
+			},
+			boolElement(dialogPreview, "Preview", "Test the link on the sample text without saving it.", false),
+		},
+	}
+
+	appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       linkDialogURL,
+		Dialog:    dialog,
+	})
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// SubmitLinkDialog validates a submitted link dialog, and saves the link
+// unless only a preview was requested. Confirmations of bulk changes are
+// submitted to it too.
+func (p *Plugin) SubmitLinkDialog(request *model.SubmitDialogRequest) *model.SubmitDialogResponse {
+	if request.Cancelled {
+		return nil
+	}
+	if request.CallbackId == dialogCallbackBulk {
+		return p.submitBulkDialog(request)
+	}
+
+	var state linkDialogState
+	if err := json.Unmarshal([]byte(request.State), &state); err != nil {
+		return &model.SubmitDialogResponse{Error: "Invalid dialog state, please try again."}
+	}
+
+	header := &model.CommandArgs{
+		UserId:    request.UserId,
+		TeamId:    request.TeamId,
+		ChannelId: request.ChannelId,
+	}
+	action := "add"
+	if state.Before != nil {
+		action = "set"
+	}
+
+	level, err := p.getPermissionLevel(request.UserId)
+	if err != nil {
+		return &model.SubmitDialogResponse{Error: err.Error()}
+	}
+	if level < permissionEdit && !p.isDelegatedAdmin(header) {
+		p.audit(AuditEvent{
+			Source: auditSourceDialog,
+			UserID: request.UserId,
+			Action: action,
+			Result: auditResultDenied,
+		})
+		return &model.SubmitDialogResponse{Error: "You do not have permission to manage links."}
+	}
+
+	l, errs := linkFromSubmission(request.Submission, state.Before)
+	if state.Before == nil {
+		l.Owner = request.UserId
+		// Links added by team and channel admins default to the current
+		// team or channel
+		if level < permissionEdit && len(l.Scope) == 0 {
+			l.Scope, err = p.delegatedScope(header)
+			if err != nil {
+				return &model.SubmitDialogResponse{Error: err.Error()}
+			}
+		}
+	}
+
+	// Validate and test a compiled copy, the saved link is compiled when
+	// the configuration is reloaded
+	test := l
+	links := append([]autolink.Autolink{}, p.getConfig().Links...)
+	n := -1
+	if state.Before != nil {
+		for i := range links {
+			if links[i].Equals(*state.Before) {
+				n = i
+				break
+			}
+		}
+	}
+	if l.Name != "" && (state.Before == nil || l.Name != state.Before.Name) {
+		if nameErr := checkLinkName(links, n, l.Name); nameErr != nil {
+			errs[dialogName] = nameErr.Error()
+		}
+	}
+	if groupErr := p.getConfig().checkLinkGroup(l); groupErr != nil {
+		errs[dialogGroup] = groupErr.Error()
+	}
+	if name, undefined := p.getConfig().undefinedVariable(l); undefined {
+		errs[dialogTemplate] = fmt.Sprintf("The variable %q is not defined, see `/autolink var list`.", name)
+	} else if compileErr := p.getConfig().compileTestLink(&test); compileErr != nil {
+		errs[dialogPattern] = fmt.Sprintf("Failed to compile: %v", compileErr)
+	}
+
+	sample := submissionString(request.Submission, dialogSample)
+	if submissionBool(request.Submission, dialogPreview) {
+		switch {
+		case sample == "":
+			errs[dialogSample] = "Enter a sample text to preview the link, or uncheck Preview to save it."
+		case len(errs) == 0:
+			errs[dialogSample] = "Preview: " + previewLink(test, sample)
+		}
+		return &model.SubmitDialogResponse{Errors: errs}
+	}
+	if len(errs) > 0 {
+		return &model.SubmitDialogResponse{Errors: errs}
+	}
+
+	if state.Before != nil {
+		if err = authorizeLinkChangeFrom(p, auditSourceDialog, header, action, *state.Before); err != nil {
+			return &model.SubmitDialogResponse{Error: err.Error()}
+		}
+	}
+	if err = authorizeLinkChangeFrom(p, auditSourceDialog, header, action, l); err != nil {
+		return &model.SubmitDialogResponse{Error: err.Error()}
+	}
+
+	switch {
+	case state.Before == nil:
+		links = append(links, l)
+	case n < 0:
+		return &model.SubmitDialogResponse{Error: "The link was changed or deleted since the dialog was opened, please open it again."}
+	default:
+		links[n] = l
+	}
+
+	if err = saveConfigLinks(p, links); err != nil {
+		return &model.SubmitDialogResponse{Error: err.Error()}
+	}
+	p.auditLinkChange(auditSourceDialog, request.UserId, "", action, state.Before, &l, auditResultSuccess)
+	if state.Before != nil {
+		p.renameUserPreferences(state.Before.Name, l.Name)
+	}
+
+	message := "Saved:\n" + l.ToMarkdown(0)
+	if sample != "" {
+		message += fmt.Sprintf("- Sample: `%s`\n- Result: `%s`\n", sample, previewLink(test, sample))
+	}
+	p.API.SendEphemeralPost(request.UserId, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: request.ChannelId,
+		Message:   message,
+	})
+
+	return nil
+}
+
+// previewLink returns what the compiled link l makes of sample.
+func previewLink(l autolink.Autolink, sample string) string {
+	if l.Action != autolink.ActionReject {
+		return l.Replace(sample)
+	}
+	if reason, rejected := l.Rejection(sample); rejected {
+		return "rejected with " + reason
+	}
+	return sample
+}
+
+// linkFromSubmission builds the link submitted in the dialog, and returns the
+// errors of the elements that are not valid. The fields that are not in the
+// dialog are kept from before.
+func linkFromSubmission(submission map[string]any, before *autolink.Autolink) (autolink.Autolink, map[string]string) {
+	l := autolink.Autolink{}
+	if before != nil {
+		l.OwnerPluginID = before.OwnerPluginID
+		l.Owner = before.Owner
+	}
+
+	errs := map[string]string{}
+	l.Name = strings.TrimSpace(submissionString(submission, dialogName))
+	l.Pattern = strings.TrimSpace(submissionString(submission, dialogPattern))
+	l.Template = strings.TrimSpace(submissionString(submission, dialogTemplate))
+	l.Scope = strings.Fields(submissionString(submission, dialogScope))
+	l.WordMatch = submissionBool(submission, dialogWordMatch)
+	l.DisableNonWordPrefix = submissionBool(submission, dialogDisableNonWordPrefix)
+	l.DisableNonWordSuffix = submissionBool(submission, dialogDisableNonWordSuffix)
+	if value := submissionString(submission, dialogProcessBotPosts); value != "" {
+		processBotPosts := value == "true"
+		l.ProcessBotPosts = &processBotPosts
+	}
+	l.UserOptional = submissionBool(submission, dialogUserOptional)
+	l.Disabled = submissionBool(submission, dialogDisabled)
+	l.Action = submissionString(submission, dialogAction)
+	if err := l.CheckAction(); err != nil {
+		errs[dialogAction] = err.Error()
+	} else if err := l.CheckUserOptional(); err != nil {
+		errs[dialogUserOptional] = err.Error()
+	}
+	l.AuditRedactions = submissionBool(submission, dialogAuditRedactions)
+	l.Group = strings.TrimSpace(submissionString(submission, dialogGroup))
+	for name, field := range map[string]**time.Time{dialogActiveFrom: &l.ActiveFrom, dialogActiveUntil: &l.ActiveUntil} {
+		value := strings.TrimSpace(submissionString(submission, name))
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs[name] = fmt.Sprintf("%q is not a time such as 2024-06-01T09:00:00Z", value)
+			continue
+		}
+		*field = &t
+	}
+	return l, errs
+}
+
+func submissionString(submission map[string]any, name string) string {
+	value, _ := submission[name].(string)
+	return value
+}
+
+func submissionBool(submission map[string]any, name string) bool {
+	switch value := submission[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+func executeEdit(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+
+	links, refs, err := searchLinkRef(p, true, args...)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	l := links[refs[0]]
+	if err = authorizeLinkChange(p, header, "set", l); err != nil {
+		return responsef("%v", err)
+	}
+
+	if err = p.openLinkDialog(header.TriggerId, &l); err != nil {
+		return responsef("failed to open the dialog: %v", err)
+	}
+	return &model.CommandResponse{}
+}
@@ -0,0 +1,206 @@
+package autolinkplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestLinkDialog(t *testing.T) {
+	activeFrom := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:       "Jira",
+			Pattern:    "(?P<key>MM-\\d+)",
+			Template:   "[$key](https://jira/$key)",
+			Owner:      "adminId",
+			ActiveFrom: &activeFrom,
+		}},
+	}
+
+	var opened model.OpenDialogRequest
+	var ephemeral *model.Post
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	api.On("GetChannel", "channelId").Return(&model.Channel{Id: "channelId", Name: "town-square", TeamId: "teamId"}, nil)
+	api.On("GetTeam", "teamId").Return(&model.Team{Id: "teamId", Name: "eng"}, nil)
+	api.On("HasPermissionToTeam", "userId", "teamId", model.PermissionManageTeam).Return(false)
+	api.On("HasPermissionToChannel", "userId", "channelId", model.PermissionManageChannelRoles).Return(false)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	api.On("OpenInteractiveDialog", mock.AnythingOfType("model.OpenDialogRequest")).Return(func(request model.OpenDialogRequest) *model.AppError {
+		opened = request
+		return nil
+	})
+	api.On("SendEphemeralPost", "adminId", mock.AnythingOfType("*model.Post")).Return(func(_ string, post *model.Post) *model.Post {
+		ephemeral = post
+		return post
+	})
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+		UserId:    "adminId",
+		ChannelId: "channelId",
+		TriggerId: "triggerId",
+		Command:   "/autolink edit Jira",
+	})
+	assert.Empty(t, resp.Text)
+	require.Equal(t, "triggerId", opened.TriggerId)
+	assert.Equal(t, linkDialogURL, opened.URL)
+	assert.Equal(t, "Edit link", opened.Dialog.Title)
+	assert.Contains(t, opened.Dialog.IntroductionText, "**Jira**")
+	require.NoError(t, opened.IsValid())
+
+	submission := map[string]any{}
+	for _, e := range opened.Dialog.Elements {
+		if e.Type == "bool" {
+			submission[e.Name] = e.Default == "true"
+		} else {
+			submission[e.Name] = e.Default
+		}
+	}
+	assert.Equal(t, "(?P<key>MM-\\d+)", submission[dialogPattern])
+
+	submit := func(userID string, changes map[string]any) *model.SubmitDialogResponse {
+		s := map[string]any{}
+		for k, v := range submission {
+			s[k] = v
+		}
+		for k, v := range changes {
+			s[k] = v
+		}
+		return p.SubmitLinkDialog(&model.SubmitDialogRequest{
+			UserId:     userID,
+			ChannelId:  "channelId",
+			State:      opened.Dialog.State,
+			Submission: s,
+		})
+	}
+
+	t.Run("preview", func(t *testing.T) {
+		resp := submit("adminId", map[string]any{
+			dialogTemplate: "[$key](https://jira.example.com/browse/$key)",
+			dialogSample:   "see MM-123",
+			dialogPreview:  true,
+		})
+		require.NotNil(t, resp)
+		assert.Equal(t, map[string]string{
+			dialogSample: "Preview: see [MM-123](https://jira.example.com/browse/MM-123)",
+		}, resp.Errors)
+		assert.Equal(t, "[$key](https://jira/$key)", p.getConfig().Links[0].Template)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		resp := submit("adminId", map[string]any{dialogPattern: "(MM-"})
+		require.NotNil(t, resp)
+		assert.Contains(t, resp.Errors[dialogPattern], "Failed to compile")
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		resp := submit("adminId", map[string]any{
+			dialogActiveUntil: "tomorrow",
+			dialogAction:      "hide",
+			dialogGroup:       "missing",
+		})
+		require.NotNil(t, resp)
+		assert.Equal(t, `"tomorrow" is not a time such as 2024-06-01T09:00:00Z`, resp.Errors[dialogActiveUntil])
+		assert.Contains(t, resp.Errors[dialogAction], `"hide" is not a valid action`)
+		assert.NotEmpty(t, resp.Errors[dialogGroup])
+
+		resp = submit("adminId", map[string]any{dialogAction: autolink.ActionRedact, dialogUserOptional: true})
+		require.NotNil(t, resp)
+		assert.Equal(t, map[string]string{dialogUserOptional: "links that redact can't be UserOptional, users must not be able to turn them off"}, resp.Errors)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		resp := submit("userId", map[string]any{dialogTemplate: "changed"})
+		require.NotNil(t, resp)
+		assert.Equal(t, "You do not have permission to manage links.", resp.Error)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		resp := submit("adminId", map[string]any{
+			dialogTemplate: "[$key](https://jira.example.com/browse/$key)",
+			dialogSample:   "see MM-123",
+		})
+		require.Nil(t, resp)
+
+		links := p.getConfig().Links
+		require.Len(t, links, 1)
+		assert.Equal(t, "[$key](https://jira.example.com/browse/$key)", links[0].Template)
+		assert.Equal(t, "adminId", links[0].Owner)
+		assert.Equal(t, &activeFrom, links[0].ActiveFrom, "ActiveFrom is round-tripped through the dialog")
+
+		require.NotNil(t, ephemeral)
+		assert.Equal(t, "channelId", ephemeral.ChannelId)
+		assert.Contains(t, ephemeral.Message, "- Result: `see [MM-123](https://jira.example.com/browse/MM-123)`")
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		resp := submit("adminId", map[string]any{dialogTemplate: "again"})
+		require.NotNil(t, resp)
+		assert.Contains(t, resp.Error, "changed or deleted since the dialog was opened")
+	})
+
+	t.Run("add", func(t *testing.T) {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    "adminId",
+			ChannelId: "channelId",
+			TriggerId: "triggerId",
+			Command:   "/autolink add",
+		})
+		assert.Empty(t, resp.Text)
+		assert.Equal(t, "Add a link", opened.Dialog.Title)
+
+		submission = map[string]any{
+			dialogName:     "GitHub",
+			dialogPattern:  "(?P<num>#\\d+)",
+			dialogTemplate: "[$num](https://github.com/$num)",
+		}
+		require.Nil(t, submit("adminId", nil))
+
+		links := p.getConfig().Links
+		require.Len(t, links, 2)
+		assert.Equal(t, "GitHub", links[1].Name)
+		assert.Equal(t, "adminId", links[1].Owner)
+
+		conflict := submit("adminId", map[string]any{dialogName: "Jira"})
+		require.NotNil(t, conflict)
+		assert.NotEmpty(t, conflict.Errors[dialogName])
+		assert.Len(t, p.getConfig().Links, 2)
+	})
+
+	t.Run("long name", func(t *testing.T) {
+		name := "Jira Cloud Production Tickets"
+		require.Nil(t, submit("adminId", map[string]any{dialogName: name}))
+
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    "adminId",
+			ChannelId: "channelId",
+			TriggerId: "triggerId",
+			Command:   `/autolink edit "` + name + `"`,
+		})
+		assert.Empty(t, resp.Text)
+		assert.Equal(t, "Edit link", opened.Dialog.Title)
+		assert.Contains(t, opened.Dialog.IntroductionText, "**"+name+"**")
+		require.NoError(t, opened.IsValid())
+	})
+}
@@ -0,0 +1,144 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const (
+	digestJobKey   = "weekly_digest"
+	digestInterval = 7 * 24 * time.Hour
+
+	// digestTopLinks is the number of most changed links listed in the digest.
+	digestTopLinks = 5
+)
+
+// waitForDigest schedules the digest a week after the previous one. Unlike
+// the cluster package's intervals, the first digest is also sent a week
+// after the job was started, not right away.
+func waitForDigest(now time.Time, metadata cluster.JobMetadata) time.Duration {
+	if metadata.LastFinished.IsZero() {
+		return digestInterval
+	}
+	if wait := metadata.LastFinished.Add(digestInterval).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// updateDigestJob starts the weekly digest if enabled, and stops it
+// otherwise.
+func (p *Plugin) updateDigestJob(enabled bool) {
+	p.digestLock.Lock()
+	defer p.digestLock.Unlock()
+
+	switch {
+	case enabled && p.digestJob == nil:
+		job, err := cluster.Schedule(p.API, digestJobKey, waitForDigest, p.sendWeeklyDigest)
+		if err != nil {
+			p.API.LogError("Failed to schedule the weekly digest", "error", err.Error())
+			return
+		}
+		p.digestJob = job
+	case !enabled && p.digestJob != nil:
+		if err := p.digestJob.Close(); err != nil {
+			p.API.LogError("Failed to stop the weekly digest", "error", err.Error())
+		}
+		p.digestJob = nil
+	}
+}
+
+func (p *Plugin) sendWeeklyDigest() {
+	digest, err := p.buildDigest(time.Now())
+	if err != nil {
+		p.API.LogError("Failed to build the weekly digest", "error", err.Error())
+		return
+	}
+	p.notifyChannel(digest)
+}
+
+// buildDigest summarizes the state of the links, and the changes made to
+// them in the week before now.
+func (p *Plugin) buildDigest(now time.Time) (string, error) {
+	events, err := p.getAuditEvents(now.Add(-digestInterval), 0)
+	if err != nil {
+		return "", err
+	}
+
+	conf := p.getConfig()
+	enabled, disabled := 0, 0
+	for _, l := range conf.Links {
+		if l.Effective().Disabled {
+			disabled++
+		} else {
+			enabled++
+		}
+	}
+
+	p.confLock.RLock()
+	failing := len(p.compileFailures)
+	p.confLock.RUnlock()
+
+	p.digestLock.Lock()
+	rewritten := p.metrics.PostsRewritten()
+	rewrittenSinceDigest := rewritten - p.digestPostsRewritten
+	p.digestPostsRewritten = rewritten
+	p.digestLock.Unlock()
+
+	since := now.Add(-digestInterval).UnixMilli()
+	changes, denied, redacted := 0, 0, 0
+	changesByLink := map[string]int{}
+	for _, e := range events {
+		if e.Timestamp < since {
+			continue
+		}
+		if e.Result == auditResultDenied {
+			denied++
+			continue
+		}
+		if e.Action == autolink.ActionRedact {
+			redacted++
+			continue
+		}
+		changes++
+		if e.Link != "" {
+			changesByLink[e.Link]++
+		}
+	}
+
+	text := "#### Autolink weekly digest\n"
+	text += fmt.Sprintf("- Links: %d enabled, %d disabled, %d failing to compile\n", enabled, disabled, failing)
+	text += fmt.Sprintf("- Posts rewritten by this server since the previous digest: %d\n", rewrittenSinceDigest)
+	text += fmt.Sprintf("- Link changes in the past week: %d, denied attempts: %d\n", changes, denied)
+	if redacted > 0 {
+		text += fmt.Sprintf("- Audited redactions in the past week: %d\n", redacted)
+	}
+
+	if len(changesByLink) > 0 {
+		names := make([]string, 0, len(changesByLink))
+		for name := range changesByLink {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if changesByLink[names[i]] != changesByLink[names[j]] {
+				return changesByLink[names[i]] > changesByLink[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		if len(names) > digestTopLinks {
+			names = names[:digestTopLinks]
+		}
+
+		text += "- Most changed links:\n"
+		for _, name := range names {
+			text += fmt.Sprintf("  - %s: %d\n", name, changesByLink[name])
+		}
+	}
+
+	return text, nil
+}
@@ -0,0 +1,90 @@
+package autolinkplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestWaitForDigest(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, digestInterval, waitForDigest(now, cluster.JobMetadata{}))
+	assert.Equal(t, 24*time.Hour, waitForDigest(now, cluster.JobMetadata{LastFinished: now.Add(-6 * 24 * time.Hour)}))
+	assert.Equal(t, time.Duration(0), waitForDigest(now, cluster.JobMetadata{LastFinished: now.Add(-8 * 24 * time.Hour)}))
+}
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	events := []AuditEvent{
+		{Timestamp: now.Add(-8 * 24 * time.Hour).UnixMilli(), Action: "set", Link: "Old", Result: auditResultSuccess},
+		{Timestamp: now.Add(-time.Hour).UnixMilli(), Action: "set", Link: "Jira", Result: auditResultSuccess},
+		{Timestamp: now.Add(-time.Hour).UnixMilli(), Action: "disable", Link: "Jira", Result: auditResultSuccess},
+		{Timestamp: now.Add(-time.Hour).UnixMilli(), Action: "add", Link: "Visa", Result: auditResultSuccess},
+		{Timestamp: now.Add(-time.Hour).UnixMilli(), Action: "delete", Link: "Visa", Result: auditResultDenied},
+		{Timestamp: now.Add(-time.Hour).UnixMilli(), Action: "redact", Link: "Visa", Result: auditResultSuccess},
+	}
+	api := &plugintest.API{}
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	for _, e := range events {
+		p.audit(e)
+	}
+	p.UpdateConfig(func(conf *Config) {
+		conf.Links = []autolink.Autolink{{Name: "Jira", Disabled: true}, {Name: "Visa"}, {Name: "Broken"}}
+	})
+	p.compileFailures = map[string]string{"Broken": "("}
+	p.metrics.IncPostsRewritten()
+	p.metrics.IncPostsRewritten()
+
+	digest, err := p.buildDigest(now)
+	require.NoError(t, err)
+	assert.Equal(t, "#### Autolink weekly digest\n"+
+		"- Links: 2 enabled, 1 disabled, 1 failing to compile\n"+
+		"- Posts rewritten by this server since the previous digest: 2\n"+
+		"- Link changes in the past week: 3, denied attempts: 1\n"+
+		"- Audited redactions in the past week: 1\n"+
+		"- Most changed links:\n"+
+		"  - Jira: 2\n"+
+		"  - Visa: 1\n", digest)
+
+	p.metrics.IncPostsRewritten()
+	digest, err = p.buildDigest(now)
+	require.NoError(t, err)
+	assert.Contains(t, digest, "- Posts rewritten by this server since the previous digest: 1\n")
+}
+
+func TestNotifyChannel(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetTeamByName", "eng").Return(&model.Team{Id: "engId", Name: "eng"}, nil)
+	api.On("GetChannelByName", "engId", "autolink-admins", false).Return(&model.Channel{Id: "adminsId"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{}, nil)
+
+	p := New()
+	p.SetAPI(api)
+	p.botUserID = "botUserId"
+
+	// Nothing is posted without a notification channel
+	p.notifyChannel("hello")
+	api.AssertNotCalled(t, "CreatePost", mock.Anything)
+
+	p.UpdateConfig(func(conf *Config) {
+		conf.NotificationChannel = "Eng/autolink-admins"
+	})
+	p.notifyChannel("hello")
+	api.AssertCalled(t, "CreatePost", &model.Post{
+		UserId:    "botUserId",
+		ChannelId: "adminsId",
+		Message:   "hello",
+	})
+}
@@ -0,0 +1,89 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const (
+	expiryJobKey = "link_expiry"
+
+	// expiryCheckInterval is how often expired links are disabled. Links
+	// are not applied after they expire, whether they were disabled yet or
+	// not.
+	expiryCheckInterval = time.Minute
+)
+
+// hasExpiringLinks reports whether some enabled link has an end.
+func hasExpiringLinks(links []autolink.Autolink) bool {
+	for _, l := range links {
+		if !l.Disabled && l.ActiveUntil != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// updateExpiryJob starts the job that disables the expired links if enabled,
+// and stops it otherwise.
+func (p *Plugin) updateExpiryJob(enabled bool) {
+	p.expiryLock.Lock()
+	defer p.expiryLock.Unlock()
+
+	switch {
+	case enabled && p.expiryJob == nil:
+		job, err := cluster.Schedule(p.API, expiryJobKey, cluster.MakeWaitForRoundedInterval(expiryCheckInterval), func() {
+			p.disableExpiredLinks(time.Now())
+		})
+		if err != nil {
+			p.API.LogError("Failed to schedule the link expiry job", "error", err.Error())
+			return
+		}
+		p.expiryJob = job
+	case !enabled && p.expiryJob != nil:
+		if err := p.expiryJob.Close(); err != nil {
+			p.API.LogError("Failed to stop the link expiry job", "error", err.Error())
+		}
+		p.expiryJob = nil
+	}
+}
+
+// disableExpiredLinks disables the enabled links whose ActiveUntil is before
+// now, and tells their owners.
+func (p *Plugin) disableExpiredLinks(now time.Time) {
+	links := append([]autolink.Autolink{}, p.GetLinks()...)
+
+	disabled := []autolink.Autolink{}
+	enabled := []autolink.Autolink{}
+	for i := range links {
+		if links[i].Disabled || links[i].ActiveUntil == nil || now.Before(*links[i].ActiveUntil) {
+			continue
+		}
+		enabled = append(enabled, links[i])
+		links[i].Disabled = true
+		disabled = append(disabled, links[i])
+	}
+	if len(disabled) == 0 {
+		return
+	}
+
+	if err := p.SaveLinks(links); err != nil {
+		p.API.LogError("Failed to disable expired links", "error", err.Error())
+		return
+	}
+
+	for i, l := range disabled {
+		p.auditLinkChange(auditSourcePlugin, "", "", "disable", &enabled[i], &disabled[i], auditResultSuccess)
+		p.API.LogInfo("Disabled an expired link", "link", l.DisplayName())
+		message := fmt.Sprintf(
+			"Link **%s** expired at %s and was disabled. "+
+				"To apply it again, change or clear its end with `/autolink set %s ActiveUntil \"\"`, then enable it with `/autolink enable %s`.\n%s",
+			l.DisplayName(), l.ActiveUntil.Format(time.RFC3339), quoteArg(l.DisplayName()), quoteArg(l.DisplayName()), l.ToMarkdown(0))
+		p.notifyOwner(l, message)
+		p.notifyChannel(message)
+	}
+}
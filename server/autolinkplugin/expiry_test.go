@@ -0,0 +1,146 @@
+package autolinkplugin
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// mockScheduledJob makes the job scheduled with key wait until its next run,
+// so that the tests call it themselves.
+func mockScheduledJob(api *plugintest.API, key string) {
+	metadata, _ := json.Marshal(cluster.JobMetadata{LastFinished: time.Now()})
+	api.On("KVGet", "cron_"+key).Return(metadata, nil)
+	api.On("KVSetWithOptions", "mutex_cron_"+key, mock.Anything, mock.Anything).Return(true, nil)
+}
+
+func TestLinkSchedule(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	conf := Config{
+		EnableAdminCommand:  true,
+		NotificationChannel: "eng/autolink-admins",
+		Links: []autolink.Autolink{{
+			Name:        "Expired",
+			Pattern:     "(expired)",
+			Template:    "EXPIRED",
+			ActiveUntil: &past,
+			Owner:       "ownerId",
+		}, {
+			Name:       "Upcoming",
+			Pattern:    "(upcoming)",
+			Template:   "UPCOMING",
+			ActiveFrom: &future,
+		}, {
+			Name:        "Current",
+			Pattern:     "(current)",
+			Template:    "CURRENT",
+			ActiveFrom:  &past,
+			ActiveUntil: &future,
+		}},
+	}
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("LogInfo", mock.AnythingOfType("string"), "link", "Expired").Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("GetChannel", "channelId").Return(&model.Channel{Id: "channelId", Name: "town-square", TeamId: "teamId"}, nil)
+	mockAPI.On("GetTeam", "teamId").Return(&model.Team{Id: "teamId", Name: "eng"}, nil)
+	// The scheduled jobs call the API in the background, the saves are
+	// counted rather than read from the recorded calls
+	var saves atomic.Int32
+	mockAPI.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Run(func(mock.Arguments) {
+		saves.Add(1)
+	}).Return(nil)
+	mockAPI.On("EnsureBotUser", mock.AnythingOfType("*model.Bot")).Return("botUserId", nil)
+	mockAPI.On("GetTeamByName", "eng").Return(&model.Team{Id: "teamId", Name: "eng"}, nil)
+	mockAPI.On("GetChannelByName", "teamId", "autolink-admins", false).Return(&model.Channel{Id: "adminsId"}, nil)
+	mockAPI.On("GetDirectChannel", "ownerId", "botUserId").Return(&model.Channel{Id: "dmChannelId"}, nil)
+	mockAPI.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{}, nil)
+	mockScheduledJob(mockAPI, expiryJobKey)
+	mockScheduledJob(mockAPI, digestJobKey)
+	getAuditLog := mockAuditLog(mockAPI)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+	require.NoError(t, p.OnActivate())
+	defer func() { require.NoError(t, p.OnDeactivate()) }()
+
+	t.Run("only the active links are applied", func(t *testing.T) {
+		post, _ := p.ProcessPost(nil, &model.Post{UserId: "adminId", ChannelId: "channelId", Message: "expired upcoming current"})
+		assert.Equal(t, "expired upcoming CURRENT", post.Message)
+	})
+
+	t.Run("the trace tells why a link is skipped", func(t *testing.T) {
+		text := formatTrace(p.traceLinks("adminId", "channelId", "expired upcoming"))
+		assert.Contains(t, text, "- Link Expired: skipped, expired at "+past.Format(time.RFC3339)+"\n")
+		assert.Contains(t, text, "- Link Upcoming: skipped, not active before "+future.Format(time.RFC3339)+"\n")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		run := func(command string) string {
+			resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{UserId: "adminId", ChannelId: "channelId", Command: command})
+			return resp.Text
+		}
+
+		assert.Contains(t, run("/autolink set Upcoming ActiveFrom tomorrow"), `"tomorrow" is not a time such as 2024-06-01T09:00:00Z`)
+		run("/autolink set Upcoming ActiveFrom 2024-06-01T09:00:00Z")
+		require.NotNil(t, p.getConfig().Links[1].ActiveFrom)
+		assert.Equal(t, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), p.getConfig().Links[1].ActiveFrom.UTC())
+
+		run(`/autolink set Upcoming ActiveFrom ""`)
+		assert.Nil(t, p.getConfig().Links[1].ActiveFrom)
+	})
+
+	t.Run("expired links are disabled", func(t *testing.T) {
+		p.disableExpiredLinks(now)
+
+		links := p.GetLinks()
+		assert.True(t, links[0].Disabled)
+		assert.False(t, links[1].Disabled)
+		assert.False(t, links[2].Disabled)
+
+		events := getAuditLog()
+		require.NotEmpty(t, events)
+		last := events[len(events)-1]
+		assert.Equal(t, auditSourcePlugin, last.Source)
+		assert.Equal(t, "disable", last.Action)
+		assert.Equal(t, "Expired", last.Link)
+
+		mockAPI.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "dmChannelId"
+		}))
+		mockAPI.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "adminsId"
+		}))
+
+		saved := saves.Load()
+		p.disableExpiredLinks(now)
+		assert.Equal(t, saved, saves.Load(), "disabled links are left alone")
+	})
+}
+
+func TestHasExpiringLinks(t *testing.T) {
+	until := time.Now()
+	assert.False(t, hasExpiringLinks(nil))
+	assert.False(t, hasExpiringLinks([]autolink.Autolink{{ActiveFrom: &until}}))
+	assert.False(t, hasExpiringLinks([]autolink.Autolink{{ActiveUntil: &until, Disabled: true}}))
+	assert.True(t, hasExpiringLinks([]autolink.Autolink{{}, {ActiveUntil: &until}}))
+}
@@ -0,0 +1,107 @@
+package autolinkplugin
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// unsettableFields are the stored fields of a link that `/autolink set` can't
+// change, they are maintained by the plugin.
+var unsettableFields = map[string]bool{
+	"OwnerPluginID": true,
+	"Owner":         true,
+}
+
+// fieldHelpTexts describe the fields in the autocomplete of `/autolink set`.
+var fieldHelpTexts = map[string]string{
+	"WordMatch":       "If true uses the \\b word boundaries",
+	"ProcessBotPosts": "If true applies changes to posts created by bot accounts, \"\" to use the setting of the group.",
+	"Scope":           "team/channel the autolink applies to",
+	"UserOptional":    "If true users can turn the link off for their own posts",
+	"ActiveFrom":      "Time the link starts to apply, e.g. 2024-06-01T09:00:00Z, empty to apply it right away",
+	"ActiveUntil":     "Time the link stops applying and is disabled, e.g. 2024-06-30T18:00:00Z, empty to keep it",
+	"Action":          "redact to mask the matches and warn the author, reject to reject the post with the template as the reason, empty to replace them",
+	"AuditRedactions": "If true records the posts redacted by the link in the audit log",
+}
+
+// settableFields returns the names of the fields of a link that
+// `/autolink set` can change, in the order of the Autolink struct.
+func settableFields() []string {
+	fields := []string{}
+	t := reflect.TypeOf(autolink.Autolink{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("json") == "-" || unsettableFields[field.Name] {
+			continue
+		}
+		fields = append(fields, field.Name)
+	}
+	return fields
+}
+
+// setLinkField sets the field of l named name. String fields are set to value,
+// boolean fields to value parsed as a bool, time fields to value parsed as an
+// RFC 3339 time or unset if value is empty, and list fields to args.
+func setLinkField(l *autolink.Autolink, name, value string, args []string) error {
+	settable := false
+	for _, field := range settableFields() {
+		if field == name {
+			settable = true
+			break
+		}
+	}
+	if !settable {
+		return errors.Errorf("%q is not a supported field, must be one of %q", name, settableFields())
+	}
+
+	field := reflect.ValueOf(l).Elem().FieldByName(name)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+		if name == "Action" {
+			if err := l.CheckAction(); err != nil {
+				return err
+			}
+			return l.CheckUserOptional()
+		}
+	case reflect.Bool:
+		boolValue, err := parseBoolArg(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolValue)
+		if name == "UserOptional" {
+			return l.CheckUserOptional()
+		}
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(append([]string{}, args...)))
+	case reflect.Ptr:
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		switch field.Type() {
+		case reflect.TypeOf(&time.Time{}):
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return errors.Errorf("%q is not a time such as 2024-06-01T09:00:00Z", value)
+			}
+			field.Set(reflect.ValueOf(&t))
+		case reflect.TypeOf(new(bool)):
+			boolValue, err := parseBoolArg(value)
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(&boolValue))
+		default:
+			return errors.Errorf("field %q can't be set", name)
+		}
+	default:
+		return errors.Errorf("field %q can't be set", name)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestSettableFields(t *testing.T) {
+	fields := settableFields()
+	assert.Contains(t, fields, "Pattern")
+	assert.Contains(t, fields, "DisableNonWordPrefix")
+	assert.Contains(t, fields, "Scope")
+	assert.NotContains(t, fields, "Owner")
+	assert.NotContains(t, fields, "OwnerPluginID")
+
+	// The autocomplete of `/autolink set` offers every settable field
+	var items []model.AutocompleteListItem
+	for _, command := range getAutoCompleteData().SubCommands {
+		if command.Trigger == "set" {
+			require.Len(t, command.Arguments, 2)
+			items = command.Arguments[1].Data.(*model.AutocompleteStaticListArg).PossibleArguments
+		}
+	}
+	autocompleted := []string{}
+	for _, item := range items {
+		autocompleted = append(autocompleted, item.Item)
+	}
+	assert.Equal(t, fields, autocompleted)
+}
+
+func TestSetLinkField(t *testing.T) {
+	l := autolink.Autolink{Name: "Jira"}
+
+	require.NoError(t, setLinkField(&l, "Template", "[$key](https://jira/$key)", nil))
+	assert.Equal(t, "[$key](https://jira/$key)", l.Template)
+
+	require.NoError(t, setLinkField(&l, "DisableNonWordSuffix", "true", nil))
+	assert.True(t, l.DisableNonWordSuffix)
+
+	require.NoError(t, setLinkField(&l, "Scope", "eng", []string{"eng", "sales/general"}))
+	assert.Equal(t, []string{"eng", "sales/general"}, l.Scope)
+
+	assert.Error(t, setLinkField(&l, "WordMatch", "maybe", nil))
+	assert.Error(t, setLinkField(&l, "Owner", "userId", nil))
+	assert.Error(t, setLinkField(&l, "Unknown", "value", nil))
+}
@@ -0,0 +1,256 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// groupChangeDescriptions describes the audited changes of groups in the
+// messages posted to the notification channel.
+var groupChangeDescriptions = map[string]string{
+	"group-add":     "added",
+	"group-set":     "changed",
+	"group-enable":  "enabled",
+	"group-disable": "disabled",
+	"group-delete":  "deleted",
+}
+
+// groupMembers returns the names of the links of the group name.
+func groupMembers(links []autolink.Autolink, name string) []string {
+	members := []string{}
+	for _, l := range links {
+		if l.Group != "" && strings.EqualFold(l.Group, name) {
+			members = append(members, "**"+l.DisplayName()+"**")
+		}
+	}
+	return members
+}
+
+// describeMembers lists the links of a group, for the command output.
+func describeMembers(members []string) string {
+	switch len(members) {
+	case 0:
+		return "no links"
+	case 1:
+		return "1 link: " + members[0]
+	}
+	return fmt.Sprintf("%d links: %s", len(members), strings.Join(members, ", "))
+}
+
+// checkLinkGroup returns an error if l names a group that doesn't exist.
+func (conf *Config) checkLinkGroup(l autolink.Autolink) error {
+	if l.Group != "" && conf.group(l.Group) == nil {
+		return errors.Errorf("there is no group named %q, see `/autolink group list`", l.Group)
+	}
+	return nil
+}
+
+// searchGroup returns the index of the group named name in groups.
+func searchGroup(groups []autolink.LinkGroup, name string) (int, error) {
+	for i, g := range groups {
+		if strings.EqualFold(g.Name, name) {
+			return i, nil
+		}
+	}
+	return -1, errors.Errorf("there is no group named %q, see `/autolink group list`", name)
+}
+
+// currentGroups returns a copy of the groups, to be changed and saved.
+func currentGroups(p *Plugin) []autolink.LinkGroup {
+	groups := []autolink.LinkGroup{}
+	for _, g := range p.getConfig().Groups {
+		g.Scope = append([]string(nil), g.Scope...)
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func executeGroupList(p *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 0 {
+		return responsef(helpText)
+	}
+	conf := p.getConfig()
+
+	out := "###### Link groups\n"
+	if len(conf.Groups) == 0 {
+		out += "No groups are defined. Add one with `/autolink group add <name>`, and add links to it with `/autolink set <linkref> Group <name>`.\n"
+	}
+	for _, g := range conf.Groups {
+		out += "- " + g.Name
+		if g.Disabled {
+			out += " **Disabled**"
+		}
+		out += "\n"
+		if g.ProcessBotPosts {
+			out += fmt.Sprintf("  - ProcessBotPosts: `%v`\n", g.ProcessBotPosts)
+		}
+		if len(g.Scope) != 0 {
+			out += fmt.Sprintf("  - Scope: `%v`\n", g.Scope)
+		}
+		out += fmt.Sprintf("  - Links: %s\n", describeMembers(groupMembers(conf.Links, g.Name)))
+	}
+	return responsef("%s", out)
+}
+
+func executeGroupAdd(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+	name := args[0]
+	if strings.TrimSpace(name) == "" {
+		return responsef("the name of a group can't be empty")
+	}
+
+	groups := currentGroups(p)
+	if _, err := searchGroup(groups, name); err == nil {
+		return responsef("There is already a group named %q.", name)
+	}
+	groups = append(groups, autolink.LinkGroup{Name: name})
+
+	if err := saveConfigGroups(p, groups); err != nil {
+		return responsef(err.Error())
+	}
+	p.auditGroupChange(header.UserId, "group-add", name, nil)
+
+	return responsef("Group `%s` added. Add links to it with `/autolink set <linkref> Group %s`.", name, name)
+}
+
+func executeGroupDelete(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+
+	groups := currentGroups(p)
+	i, err := searchGroup(groups, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	name := groups[i].Name
+	if members := groupMembers(p.getConfig().Links, name); len(members) > 0 {
+		return responsef("Group `%s` has %s, remove them from the group first.", name, describeMembers(members))
+	}
+	groups = append(groups[:i], groups[i+1:]...)
+
+	if err = saveConfigGroups(p, groups); err != nil {
+		return responsef(err.Error())
+	}
+	p.auditGroupChange(header.UserId, "group-delete", name, nil)
+
+	return responsef("Group `%s` deleted.", name)
+}
+
+func executeGroupEnable(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	return executeGroupEnableImpl(p, header, args, true)
+}
+
+func executeGroupDisable(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	return executeGroupEnableImpl(p, header, args, false)
+}
+
+func executeGroupEnableImpl(p *Plugin, header *model.CommandArgs, args []string, enabled bool) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+
+	groups := currentGroups(p)
+	i, err := searchGroup(groups, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	g := &groups[i]
+	change := FieldChange{Field: "Disabled", Old: fmt.Sprint(g.Disabled), New: fmt.Sprint(!enabled)}
+	g.Disabled = !enabled
+
+	if err = saveConfigGroups(p, groups); err != nil {
+		return responsef(err.Error())
+	}
+	action := "group-enable"
+	if !enabled {
+		action = "group-disable"
+	}
+	p.auditGroupChange(header.UserId, action, g.Name, []FieldChange{change})
+
+	return responsef("Group `%s` %s, with %s.", g.Name, groupChangeDescriptions[action], describeMembers(groupMembers(p.getConfig().Links, g.Name)))
+}
+
+func executeGroupSet(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) < 3 {
+		return responsef(helpText)
+	}
+
+	groups := currentGroups(p)
+	i, err := searchGroup(groups, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	g := &groups[i]
+
+	var change FieldChange
+	switch field := args[1]; field {
+	case "Scope":
+		change = FieldChange{Field: field, Old: strings.Join(g.Scope, " "), New: strings.Join(args[2:], " ")}
+		g.Scope = append([]string{}, args[2:]...)
+	case "ProcessBotPosts":
+		value, boolErr := parseBoolArg(args[2])
+		if boolErr != nil {
+			return responsef("%v", boolErr)
+		}
+		change = FieldChange{Field: field, Old: fmt.Sprint(g.ProcessBotPosts), New: fmt.Sprint(value)}
+		g.ProcessBotPosts = value
+	default:
+		return responsef("%q is not a supported field, must be one of %q", field, []string{"Scope", "ProcessBotPosts"})
+	}
+
+	if err = saveConfigGroups(p, groups); err != nil {
+		return responsef(err.Error())
+	}
+	p.auditGroupChange(header.UserId, "group-set", g.Name, []FieldChange{change})
+
+	return responsef("Group `%s` changed, with %s.", g.Name, describeMembers(groupMembers(p.getConfig().Links, g.Name)))
+}
+
+// auditGroupChange records the change of a group, and tells the notification
+// channel about it.
+func (p *Plugin) auditGroupChange(userID, action, name string, changes []FieldChange) {
+	p.audit(AuditEvent{
+		Source:  auditSourceCommand,
+		UserID:  userID,
+		Action:  action,
+		Link:    name,
+		Changes: changes,
+		Result:  auditResultSuccess,
+	})
+
+	if p.getConfig().NotificationChannel == "" {
+		return
+	}
+	message := fmt.Sprintf("Group **%s** was %s by %s, with %s.\n", name, groupChangeDescriptions[action],
+		p.describeActor(auditSourceCommand, userID, ""), describeMembers(groupMembers(p.getConfig().Links, name)))
+	for _, c := range changes {
+		message += fmt.Sprintf("- %s: `%s` → `%s`\n", c.Field, c.Old, c.New)
+	}
+	p.notifyChannel(message)
+}
+
+func saveConfigGroups(p *Plugin, groups []autolink.LinkGroup) error {
+	p.UpdateConfig(func(conf *Config) {
+		conf.Groups = groups
+	})
+
+	configMap, err := p.getConfig().ToMap()
+	if err != nil {
+		return err
+	}
+
+	appErr := p.API.SavePluginConfig(configMap)
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestLinkGroups(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Groups: []autolink.LinkGroup{
+			{Name: "Jira", Scope: []string{"eng"}, ProcessBotPosts: true},
+			{Name: "Masks", Disabled: true},
+			{Name: "Empty"},
+		},
+		Links: []autolink.Autolink{{
+			Name:     "MM",
+			Pattern:  "(?P<key>MM-\\d+)",
+			Template: "[$key](https://jira/$key)",
+			Group:    "Jira",
+		}, {
+			Name:     "Sales",
+			Pattern:  "(?P<key>SALES-\\d+)",
+			Template: "[$key](https://jira/$key)",
+			Group:    "jira",
+			Scope:    []string{"sales"},
+		}, {
+			Name:     "Visa",
+			Pattern:  "(?P<last>4111-\\d+)",
+			Template: "VISA $last",
+			Group:    "Masks",
+		}},
+	}
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("GetUser", "botId").Return(&model.User{Id: "botId", IsBot: true}, nil)
+	mockAPI.On("GetChannel", "engChannelId").Return(&model.Channel{Id: "engChannelId", Name: "town-square", TeamId: "engId"}, nil)
+	mockAPI.On("GetTeam", "engId").Return(&model.Team{Id: "engId", Name: "eng"}, nil)
+	mockAPI.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	getAuditLog := mockAuditLog(mockAPI)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    "adminId",
+			ChannelId: "engChannelId",
+			Command:   command,
+		})
+		return resp.Text
+	}
+
+	t.Run("links inherit the settings of their group", func(t *testing.T) {
+		// The bot check is skipped, the group processes bot posts
+		post, _ := p.ProcessPost(nil, &model.Post{UserId: "botId", ChannelId: "engChannelId", Message: "MM-1 SALES-2 4111-1234"})
+		assert.Equal(t, "[MM-1](https://jira/MM-1) SALES-2 4111-1234", post.Message)
+	})
+
+	t.Run("a link overrides the group's ProcessBotPosts", func(t *testing.T) {
+		run("/autolink set MM ProcessBotPosts false")
+		require.NotNil(t, p.getConfig().Links[0].ProcessBotPosts)
+		post, _ := p.ProcessPost(nil, &model.Post{UserId: "botId", ChannelId: "engChannelId", Message: "MM-1"})
+		assert.Equal(t, "MM-1", post.Message)
+		assert.Contains(t, run("/autolink list MM"), "  - ProcessBotPosts: `false`\n")
+
+		run(`/autolink set MM ProcessBotPosts ""`)
+		assert.Nil(t, p.getConfig().Links[0].ProcessBotPosts)
+		post, _ = p.ProcessPost(nil, &model.Post{UserId: "botId", ChannelId: "engChannelId", Message: "MM-1"})
+		assert.Equal(t, "[MM-1](https://jira/MM-1)", post.Message)
+	})
+
+	t.Run("list shows the effective values", func(t *testing.T) {
+		text := run("/autolink list MM")
+		assert.Contains(t, text, "  - Scope: `[eng]` (from group `Jira`)\n")
+		assert.Contains(t, text, "  - ProcessBotPosts: `true` (from group `Jira`)\n")
+
+		text = run("/autolink list Visa")
+		assert.Contains(t, text, "~~Visa~~ **Disabled** (from group `Masks`)")
+	})
+
+	t.Run("test applies links of disabled groups", func(t *testing.T) {
+		assert.Contains(t, run("/autolink test Visa 4111-1234"), "changed to `VISA 4111-1234`")
+	})
+
+	t.Run("group list", func(t *testing.T) {
+		assert.Equal(t, "###### Link groups\n"+
+			"- Jira\n"+
+			"  - ProcessBotPosts: `true`\n"+
+			"  - Scope: `[eng]`\n"+
+			"  - Links: 2 links: **MM**, **Sales**\n"+
+			"- Masks **Disabled**\n"+
+			"  - Links: 1 link: **Visa**\n"+
+			"- Empty\n"+
+			"  - Links: no links\n",
+			run("/autolink group list"))
+	})
+
+	t.Run("enable and disable", func(t *testing.T) {
+		assert.Equal(t, "Group `Masks` enabled, with 1 link: **Visa**.", run("/autolink group enable masks"))
+		assert.False(t, p.getConfig().Groups[1].Disabled)
+		assert.Equal(t, "Group `Jira` disabled, with 2 links: **MM**, **Sales**.", run("/autolink group disable Jira"))
+		assert.True(t, p.getConfig().Groups[0].Disabled)
+
+		events := getAuditLog()
+		require.NotEmpty(t, events)
+		last := events[len(events)-1]
+		assert.Equal(t, "group-disable", last.Action)
+		assert.Equal(t, "Jira", last.Link)
+		assert.Equal(t, []FieldChange{{Field: "Disabled", Old: "false", New: "true"}}, last.Changes)
+
+		// The links themselves are left alone
+		for _, l := range p.getConfig().Links {
+			assert.False(t, l.Disabled)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		assert.Contains(t, run("/autolink group set Jira Scope eng sales"), "Group `Jira` changed")
+		assert.Equal(t, []string{"eng", "sales"}, p.getConfig().Groups[0].Scope)
+		run("/autolink group set Jira ProcessBotPosts false")
+		assert.False(t, p.getConfig().Groups[0].ProcessBotPosts)
+		assert.Contains(t, run("/autolink group set Jira Template x"), `"Template" is not a supported field`)
+	})
+
+	t.Run("add and delete", func(t *testing.T) {
+		assert.Contains(t, run("/autolink group add Wiki"), "Group `Wiki` added")
+		assert.Equal(t, "There is already a group named \"wiki\".", run("/autolink group add wiki"))
+		require.Len(t, p.getConfig().Groups, 4)
+
+		assert.Equal(t, "Group `Jira` has 2 links: **MM**, **Sales**, remove them from the group first.", run("/autolink group delete Jira"))
+		assert.Equal(t, "Group `Wiki` deleted.", run("/autolink group delete wiki"))
+		assert.Contains(t, run("/autolink group delete Wiki"), `there is no group named "Wiki"`)
+		assert.Len(t, p.getConfig().Groups, 3)
+	})
+
+	t.Run("set the group of a link", func(t *testing.T) {
+		assert.Contains(t, run("/autolink set Visa Group Unknown"), `there is no group named "Unknown"`)
+		assert.Equal(t, "Masks", p.getConfig().Links[2].Group)
+
+		run("/autolink set Visa Group Empty")
+		assert.Equal(t, "Empty", p.getConfig().Links[2].Group)
+	})
+}
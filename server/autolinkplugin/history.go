@@ -0,0 +1,163 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const (
+	defaultHistorySize = 100
+	maxHistorySize     = 1000
+	historyPageSize    = 200
+	historySamples     = 5
+)
+
+// historyResult is the outcome of a dry run of a link on the posts of a
+// channel.
+type historyResult struct {
+	Tested int
+	// Changed counts the posts the link would change, or reject if it is a
+	// reject link.
+	Changed int
+	// Samples are the first texts the link would change, and what it
+	// would change them to, or the posts it would reject and the reason.
+	Samples [][2]string
+	// Skipped is why the link doesn't apply to the channel at all, if so.
+	Skipped string
+}
+
+// testLinkOnHistory applies l to the last n posts of channelID, without
+// changing them, as if they were posted again.
+func (p *Plugin) testLinkOnHistory(l autolink.Autolink, channelID string, n int) (*historyResult, error) {
+	conf := *p.getConfig()
+	if err := conf.compileTestLink(&l); err != nil {
+		return nil, errors.Wrapf(err, "failed to compile link %s", l.DisplayName())
+	}
+	conf.Links = []autolink.Autolink{l}
+	conf.ProcessingTimeBudget = 0
+
+	result := &historyResult{}
+	for page := 0; result.Tested < n; page++ {
+		posts, appErr := p.API.GetPostsForChannel(channelID, page, historyPageSize)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to get the posts of the channel")
+		}
+		if len(posts.Order) == 0 {
+			break
+		}
+
+		for _, id := range posts.Order {
+			post := posts.Posts[id]
+			if post == nil || post.Type != "" || post.DeleteAt != 0 {
+				continue
+			}
+			if result.Tested == n {
+				break
+			}
+			result.Tested++
+
+			// Posts the plugin already rewrote are tested as they were
+			// written
+			message := post.Message
+			if original, ok := post.GetProp(propOriginalMessage).(string); ok {
+				message = original
+			}
+
+			trace := &autolink.Trace{Message: message}
+			post = post.Clone()
+			post.Message = message
+			rewritten := p.replaceLinks(post, &conf, time.Now(), trace)
+			if rewritten.Rejection != "" {
+				result.Changed++
+				if len(result.Samples) < historySamples {
+					result.Samples = append(result.Samples, [2]string{message, rewritten.Rejection})
+				}
+				continue
+			}
+			if !rewritten.Changed {
+				if len(trace.Skipped) > 0 {
+					result.Skipped = trace.Skipped[0].Reason
+				}
+				continue
+			}
+
+			result.Changed++
+			for _, segment := range trace.Segments {
+				for _, step := range segment.Steps {
+					if step.Skipped == "" && len(result.Samples) < historySamples {
+						result.Samples = append(result.Samples, [2]string{segment.Text, step.Result})
+					}
+				}
+			}
+		}
+		if len(posts.Order) < historyPageSize {
+			break
+		}
+	}
+	return result, nil
+}
+
+func executeTestHistory(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) < 1 || len(args) > 3 {
+		return responsef(helpText)
+	}
+
+	links, refs, err := searchLinkRef(p, true, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	l := links[refs[0]]
+
+	channelID := header.ChannelId
+	channelName := ""
+	n := defaultHistorySize
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "~") {
+			channel, appErr := p.API.GetChannelByName(header.TeamId, strings.TrimPrefix(arg, "~"), false)
+			if appErr != nil {
+				return responsef("failed to get channel `%s`: %v", arg, appErr.Error())
+			}
+			channelID = channel.Id
+			channelName = arg
+			continue
+		}
+
+		n, err = strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxHistorySize {
+			return responsef("%q is not a valid number of posts, it must be between 1 and %d", arg, maxHistorySize)
+		}
+	}
+	if channelName == "" {
+		channelName = "this channel"
+	}
+
+	if !p.API.HasPermissionToChannel(header.UserId, channelID, model.PermissionReadChannel) {
+		return responsef("You can only test links on the channels you can read.")
+	}
+
+	result, err := p.testLinkOnHistory(l, channelID, n)
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	verb := "change"
+	if l.Action == autolink.ActionReject {
+		verb = "reject"
+	}
+	out := fmt.Sprintf("Link %s would %s %d of the last %d posts in %s.\n", l.DisplayName(), verb, result.Changed, result.Tested, channelName)
+	if result.Changed == 0 && result.Skipped != "" {
+		out += fmt.Sprintf("The link is skipped in %s: %s.\n", channelName, result.Skipped)
+	}
+	for _, sample := range result.Samples {
+		out += fmt.Sprintf("- `%s`\n  → `%s`\n", sample[0], sample[1])
+	}
+	return responsef("%s", out)
+}
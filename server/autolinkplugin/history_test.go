@@ -0,0 +1,100 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestTestHistory(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(?P<key>MM-\\d+)",
+			Template: "[$key](https://jira/$key)",
+			Disabled: true,
+		}, {
+			Name:     "Sales",
+			Pattern:  "(MM-\\d+)",
+			Template: "sales",
+			Scope:    []string{"sales"},
+		}},
+	}
+
+	posts := model.NewPostList()
+	add := func(post *model.Post) {
+		post.Id = fmt.Sprintf("post%d", len(posts.Order))
+		post.UserId = "userId"
+		post.ChannelId = "channelId"
+		posts.AddPost(post)
+		posts.AddOrder(post.Id)
+	}
+	add(&model.Post{Message: "fixed in MM-1"})
+	add(&model.Post{Message: "nothing to see"})
+	add(&model.Post{Message: "`MM-2` is code"})
+	add(&model.Post{Message: "joined the channel", Type: model.PostTypeJoinChannel})
+	already := &model.Post{Message: "see [MM-3](https://jira/MM-3)"}
+	already.AddProp(propOriginalMessage, "see MM-3")
+	add(already)
+	add(&model.Post{Message: "MM-4 and MM-5"})
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	mockAPI.On("GetChannel", "channelId").Return(&model.Channel{Id: "channelId", Name: "town-square", TeamId: "teamId"}, nil)
+	mockAPI.On("GetChannelByName", "teamId", "town-square", false).Return(&model.Channel{Id: "channelId", Name: "town-square", TeamId: "teamId"}, nil)
+	mockAPI.On("GetChannelByName", "teamId", "private", false).Return(&model.Channel{Id: "privateId", Name: "private", TeamId: "teamId"}, nil)
+	mockAPI.On("GetTeam", "teamId").Return(&model.Team{Id: "teamId", Name: "eng"}, nil)
+	mockAPI.On("HasPermissionToChannel", "adminId", "channelId", model.PermissionReadChannel).Return(true)
+	mockAPI.On("HasPermissionToChannel", "adminId", "privateId", model.PermissionReadChannel).Return(false)
+	mockAPI.On("GetPostsForChannel", "channelId", 0, historyPageSize).Return(posts, nil)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    "adminId",
+			TeamId:    "teamId",
+			ChannelId: "channelId",
+			Command:   command,
+		})
+		return resp.Text
+	}
+
+	assert.Equal(t, "Link Jira would change 3 of the last 5 posts in ~town-square.\n"+
+		"- `fixed in MM-1`\n  → `fixed in [MM-1](https://jira/MM-1)`\n"+
+		"- `see MM-3`\n  → `see [MM-3](https://jira/MM-3)`\n"+
+		"- `MM-4 and MM-5`\n  → `[MM-4](https://jira/MM-4) and [MM-5](https://jira/MM-5)`\n",
+		run("/autolink test-history Jira ~town-square"))
+
+	assert.Equal(t, "Link Jira would change 1 of the last 2 posts in this channel.\n"+
+		"- `fixed in MM-1`\n  → `fixed in [MM-1](https://jira/MM-1)`\n",
+		run("/autolink test-history Jira 2"))
+
+	assert.Equal(t, "Link Sales would change 0 of the last 5 posts in this channel.\n"+
+		"The link is skipped in this channel: not in scope.\n",
+		run("/autolink test-history Sales"))
+
+	assert.Contains(t, run("/autolink test-history Jira ~private"), "You can only test links on the channels you can read.")
+	assert.Contains(t, run("/autolink test-history Jira 0"), "not a valid number of posts")
+
+	// The posts are left alone
+	assert.Equal(t, "fixed in MM-1", posts.Posts["post0"].Message)
+	mockAPI.AssertNotCalled(t, "UpdatePost", mock.Anything)
+}
@@ -0,0 +1,38 @@
+package autolinkplugin
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// propOptedOut marks a post whose author opted out of autolinking with the
+// opt-out directive. Such posts are never processed again.
+const propOptedOut = "autolink_opted_out"
+
+func isOptedOut(post *model.Post) bool {
+	optedOut, _ := post.GetProp(propOptedOut).(bool)
+	return optedOut
+}
+
+// stripOptOutDirective reports whether message starts with the opt-out
+// directive, and returns the message with the directive removed.
+func stripOptOutDirective(message, directive string) (string, bool) {
+	if directive == "" {
+		return message, false
+	}
+
+	rest := strings.TrimLeftFunc(message, unicode.IsSpace)
+	if !strings.HasPrefix(rest, directive) {
+		return message, false
+	}
+	rest = rest[len(directive):]
+
+	// The directive must be a word of its own
+	if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+		return message, false
+	}
+
+	return strings.TrimLeftFunc(rest, unicode.IsSpace), true
+}
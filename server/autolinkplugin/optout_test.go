@@ -0,0 +1,77 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestStripOptOutDirective(t *testing.T) {
+	for _, tc := range []struct {
+		message   string
+		directive string
+		expected  string
+		optedOut  bool
+	}{
+		{"!nolink MM-1234", "!nolink", "MM-1234", true},
+		{"  !nolink\nMM-1234", "!nolink", "MM-1234", true},
+		{"!nolink", "!nolink", "", true},
+		{"!nolinks MM-1234", "!nolink", "!nolinks MM-1234", false},
+		{"MM-1234 !nolink", "!nolink", "MM-1234 !nolink", false},
+		{"!nolink MM-1234", "", "!nolink MM-1234", false},
+	} {
+		t.Run(tc.message, func(t *testing.T) {
+			message, optedOut := stripOptOutDirective(tc.message, tc.directive)
+			assert.Equal(t, tc.expected, message)
+			assert.Equal(t, tc.optedOut, optedOut)
+		})
+	}
+}
+
+func TestOptOut(t *testing.T) {
+	conf := Config{
+		EnableOnUpdate:     true,
+		OptOutDirective:    "!nolink",
+		EnableEscapePrefix: true,
+		Links: []autolink.Autolink{{
+			Pattern:  "MM-(?P<jira_id>\\d+)",
+			Template: "[MM-$jira_id](https://mattermost.atlassian.net/browse/MM-$jira_id)",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{}, nil)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	t.Run("directive", func(t *testing.T) {
+		post := &model.Post{Message: "!nolink the pattern is MM-1234"}
+		rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post)
+		assert.Equal(t, "the pattern is MM-1234", rpost.Message)
+
+		edited := rpost.Clone()
+		edited.Message = "the pattern is MM-1234, e.g. MM-5678"
+		rpost, _ = p.MessageWillBeUpdated(&plugin.Context{}, edited, rpost)
+		assert.Equal(t, "the pattern is MM-1234, e.g. MM-5678", rpost.Message)
+	})
+
+	t.Run("escape", func(t *testing.T) {
+		post := &model.Post{Message: `see \MM-1234 and MM-5678`}
+		rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post)
+		assert.Equal(t, "see MM-1234 and [MM-5678](https://mattermost.atlassian.net/browse/MM-5678)", rpost.Message)
+	})
+}
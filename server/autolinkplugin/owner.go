@@ -0,0 +1,104 @@
+package autolinkplugin
+
+import (
+	"fmt"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// changeDescriptions describes the audited actions in the messages about
+// link changes.
+var changeDescriptions = map[string]string{
+	"add":     "added",
+	"set":     "changed",
+	"enable":  "enabled",
+	"disable": "disabled",
+	"delete":  "deleted",
+	"rename":  "renamed",
+	"move":    "moved",
+}
+
+// notifyOwner sends message to the owner of l, if it has one.
+func (p *Plugin) notifyOwner(l autolink.Autolink, message string) {
+	if l.Owner == "" {
+		return
+	}
+	if err := p.sendDirectMessage(l.Owner, message); err != nil {
+		p.API.LogError("Failed to notify link owner", "link", l.DisplayName(), "error", err.Error())
+	}
+}
+
+// notifyOwnerOfChange tells the owner of a link that someone else changed or
+// deleted it.
+func (p *Plugin) notifyOwnerOfChange(source, userID, pluginID, action string, before, after *autolink.Autolink) {
+	if before == nil || before.Owner == "" || before.Owner == userID {
+		return
+	}
+	if _, ok := changeDescriptions[action]; !ok {
+		return
+	}
+	p.notifyOwner(*before, "Your link "+p.describeChange(source, userID, pluginID, action, before, after))
+}
+
+// describeChange describes a change of a link, starting with the link name.
+func (p *Plugin) describeChange(source, userID, pluginID, action string, before, after *autolink.Autolink) string {
+	name := ""
+	switch {
+	case before != nil:
+		name = before.DisplayName()
+	case after != nil:
+		name = after.DisplayName()
+	}
+
+	what := changeDescriptions[action]
+	if what == "" {
+		what = action
+	}
+
+	message := fmt.Sprintf("**%s** was %s by %s.", name, what, p.describeActor(source, userID, pluginID))
+	if changes := diffLinks(before, after); after != nil && len(changes) > 0 {
+		message += "\n"
+		for _, c := range changes {
+			message += fmt.Sprintf("- %s: `%s` → `%s`\n", c.Field, c.Old, c.New)
+		}
+	}
+	return message
+}
+
+// describeActor names whoever made a change, for the messages about link
+// changes.
+func (p *Plugin) describeActor(source, userID, pluginID string) string {
+	switch {
+	case userID != "":
+		user, appErr := p.API.GetUser(userID)
+		if appErr != nil {
+			return fmt.Sprintf("user `%s`", userID)
+		}
+		return "@" + user.Username
+	case pluginID != "":
+		return fmt.Sprintf("plugin `%s`", pluginID)
+	case source == auditSourceConfig:
+		return "an edit of the plugin configuration"
+	default:
+		return "the Autolink plugin"
+	}
+}
+
+// notifyCompileFailures tells the owners of the links that failed to compile,
+// and the notification channel, unless they were already told about the same
+// pattern. It returns the patterns of the failed links by name, to be passed
+// back on the next call.
+func (p *Plugin) notifyCompileFailures(failed []autolink.Autolink, errs []error, previous map[string]string, notify bool) map[string]string {
+	current := map[string]string{}
+	for i, l := range failed {
+		current[l.DisplayName()] = l.Pattern
+		if pattern, ok := previous[l.DisplayName()]; !notify || (ok && pattern == l.Pattern) {
+			continue
+		}
+		message := fmt.Sprintf("**%s** failed to compile and is not applied to posts: %v\nFix its pattern with `/autolink set %s Pattern ...`.",
+			l.DisplayName(), errs[i], l.DisplayName())
+		p.notifyOwner(l, "Your link "+message)
+		p.notifyChannel("Link " + message)
+	}
+	return current
+}
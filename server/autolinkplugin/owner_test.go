@@ -0,0 +1,89 @@
+package autolinkplugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestNotifyOwnerOfCompileFailure(t *testing.T) {
+	conf := Config{
+		Links: []autolink.Autolink{{
+			Name:     "Broken",
+			Pattern:  "(",
+			Template: "broken",
+			Owner:    "ownerId",
+		}},
+	}
+
+	messages := []string{}
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		conf.Links = append([]autolink.Autolink{}, conf.Links...)
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("LogError", mock.AnythingOfType("string"), "link", mock.Anything, "error", mock.AnythingOfType("string")).Return()
+	api.On("GetDirectChannel", "ownerId", "botUserId").Return(&model.Channel{Id: "dmChannelId"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(func(post *model.Post) *model.Post {
+		messages = append(messages, post.Message)
+		return post
+	}, nil)
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	p.botUserID = "botUserId"
+
+	// Owners are not told again about the links that failed on startup
+	require.NoError(t, p.OnConfigurationChange())
+	assert.Empty(t, messages)
+
+	conf.Links[0].Pattern = "x{2,1}"
+	require.NoError(t, p.OnConfigurationChange())
+	require.Len(t, messages, 2)
+	assert.True(t, strings.HasPrefix(messages[0], "Your link **Broken** was changed by an edit of the plugin configuration."))
+	assert.True(t, strings.HasPrefix(messages[1], "Your link **Broken** failed to compile"))
+
+	// Reloading the same configuration doesn't repeat the notification
+	require.NoError(t, p.OnConfigurationChange())
+	assert.Len(t, messages, 2)
+}
+
+func TestNotifyOwnerOfChange(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetUser", "editorId").Return(&model.User{Id: "editorId", Username: "editor"}, nil)
+	api.On("GetDirectChannel", "ownerId", "botUserId").Return(&model.Channel{Id: "dmChannelId"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{}, nil)
+
+	p := New()
+	p.SetAPI(api)
+	p.botUserID = "botUserId"
+
+	before := autolink.Autolink{Name: "Handbook", Template: "old", Owner: "ownerId"}
+	after := before
+	after.Template = "new"
+
+	// Owners are not told about their own changes
+	p.notifyOwnerOfChange(auditSourceCommand, "ownerId", "", "set", &before, &after)
+	api.AssertNotCalled(t, "CreatePost", mock.Anything)
+
+	p.notifyOwnerOfChange(auditSourceCommand, "editorId", "", "set", &before, &after)
+	api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.UserId == "botUserId" &&
+			post.Message == "Your link **Handbook** was changed by @editor.\n- Template: `old` → `new`\n"
+	}))
+
+	p.notifyOwnerOfChange(auditSourceAPI, "", "otherplugin", "delete", &before, nil)
+	api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.Message == "Your link **Handbook** was deleted by plugin `otherplugin`."
+	}))
+}
@@ -5,38 +5,97 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 	"github.com/mattermost/mattermost/server/public/shared/markdown"
 	"github.com/pkg/errors"
 
 	"github.com/mattermost-community/mattermost-plugin-autolink/server/api"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/metrics"
 )
 
 // Plugin the main struct for everything
 type Plugin struct {
 	plugin.MattermostPlugin
 
-	handler *api.Handler
+	handler   *api.Handler
+	metrics   *metrics.Metrics
+	botUserID string
 
 	// configuration and a mutex to control concurrent access
 	conf     *Config
 	confLock sync.RWMutex
+
+	// confLoaded is set once the configuration has been loaded, changes to
+	// the links are audited from then on.
+	confLoaded bool
+
+	// compileFailures has the patterns of the links that failed to compile,
+	// by name, so that their owners are only told once.
+	compileFailures map[string]string
+
+	// digestJob sends the weekly digest to the notification channel.
+	// digestPostsRewritten is the number of posts rewritten when the previous
+	// digest was sent.
+	digestJob            *cluster.Job
+	digestPostsRewritten uint64
+	digestLock           sync.Mutex
+
+	// expiryJob disables the links whose ActiveUntil has passed.
+	expiryJob  *cluster.Job
+	expiryLock sync.Mutex
+
+	// overruns counts, per link, the consecutive posts in which the link
+	// exceeded its share of the processing time budget.
+	overruns     map[string]int
+	overrunsLock sync.Mutex
+
+	// reverting has the messages of the posts being reverted, by post ID,
+	// so that the update made by revertPost is not processed as an edit.
+	reverting     map[string]string
+	revertingLock sync.Mutex
+
+	// auditSeq orders the audit events saved by this server in the same
+	// millisecond.
+	auditSeq  uint64
+	auditLock sync.Mutex
 }
 
 func New() *Plugin {
 	return &Plugin{
-		conf: new(Config),
+		conf:      new(Config),
+		metrics:   metrics.New(),
+		overruns:  map[string]int{},
+		reverting: map[string]string{},
 	}
 }
 
 func (p *Plugin) OnActivate() error {
-	p.handler = api.NewHandler(p, p)
+	botUserID, err := p.API.EnsureBotUser(&model.Bot{
+		Username:    botUsername,
+		DisplayName: botDisplayName,
+		Description: botDescription,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure bot user")
+	}
+	p.botUserID = botUserID
+
+	p.handler = api.NewHandler(p, p, p, p, p.metrics)
 
 	return nil
 }
 
+func (p *Plugin) OnDeactivate() error {
+	p.updateDigestJob(false)
+	p.updateExpiryJob(false)
+	return nil
+}
+
 func (p *Plugin) IsAuthorizedAdmin(userID string) (bool, error) {
 	user, err := p.API.GetUser(userID)
 	if err != nil {
@@ -108,14 +167,90 @@ func (p *Plugin) inScope(scope []string, channelName string, teamName string) bo
 }
 
 func (p *Plugin) ProcessPost(_ *plugin.Context, post *model.Post) (*model.Post, string) {
+	return p.processPost(post, false)
+}
+
+// processPost applies the links to post, or only the mandatory links if
+// mandatoryOnly is set. The posts that were opted out of the links or
+// reverted are always left to the mandatory links.
+func (p *Plugin) processPost(post *model.Post, mandatoryOnly bool) (*model.Post, string) {
+	startTime := time.Now()
+	defer func() {
+		p.metrics.ObserveProcessPost(time.Since(startTime))
+	}()
+
 	conf := p.getConfig()
 
+	if isReverted(post) || isOptedOut(post) {
+		mandatoryOnly = true
+	} else if message, ok := stripOptOutDirective(post.Message, conf.OptOutDirective); ok && !mandatoryOnly {
+		post.Message = message
+		post.Hashtags, _ = model.ParseHashtags(message)
+		post.AddProp(propOptedOut, true)
+		mandatoryOnly = true
+	}
+	if mandatoryOnly {
+		conf = conf.Mandatory()
+	}
+
+	result := p.replaceLinks(post, conf, startTime, nil)
+	if result.Rejection != "" {
+		return nil, result.Rejection
+	}
+	if result.Changed {
+		// The original of a redacted post holds the data that was masked, it
+		// is not kept and the post can't be reverted
+		if len(result.Redactions) == 0 {
+			post.AddProp(propOriginalMessage, post.Message)
+		} else {
+			post.DelProp(propOriginalMessage)
+		}
+		post.Message = result.Message
+		post.Hashtags, _ = model.ParseHashtags(result.Message)
+		p.metrics.IncPostsRewritten()
+	}
+	if len(result.Redactions) > 0 {
+		p.reportRedactions(post, result.Redactions)
+	}
+	return post, ""
+}
+
+// rewrite is the outcome of applying the links to a post.
+type rewrite struct {
+	Message string
+	Changed bool
+	// Redactions are what the redact links masked.
+	Redactions []redaction
+	// Rejection is the reason a reject link rejected the post, which is
+	// then left as it is.
+	Rejection string
+}
+
+// replaceLinks applies the links of conf to the message of post. If trace is
+// not nil, the links are applied without a time budget, metrics or logs, and
+// each step is recorded in it.
+func (p *Plugin) replaceLinks(post *model.Post, conf *Config, startTime time.Time, trace *autolink.Trace) rewrite {
 	message := post.Message
 	changed := false
 	offset := 0
+	var redactedMatches []int
+
+	// Links are applied with the settings they inherit from their group
+	links := effectiveLinks(conf.Links)
+
+	var deadline time.Time
+	var linkShare time.Duration
+	var linkElapsed []time.Duration
+	timedOut := false
+	if conf.ProcessingTimeBudget > 0 && trace == nil {
+		budget := time.Duration(conf.ProcessingTimeBudget) * time.Millisecond
+		deadline = startTime.Add(budget)
+		linkShare = budget / time.Duration(enabledLinkCount(links))
+		linkElapsed = make([]time.Duration, len(links))
+	}
 
 	hasOneOrMoreScopes := false
-	for _, link := range conf.Links {
+	for _, link := range links {
 		if len(link.Scope) > 0 {
 			hasOneOrMoreScopes = true
 			break
@@ -129,16 +264,86 @@ func (p *Plugin) ProcessPost(_ *plugin.Context, post *model.Post) (*model.Post,
 		channelName = cn
 		teamName = tn
 
-		if rsErr != nil {
+		if rsErr != nil && trace == nil {
+			p.metrics.IncScopeResolutionErrors()
 			p.API.LogError("Failed to resolve scope", "error", rsErr.Error())
 		}
 	}
 
+	var authorPrefs *UserPreferences
+	if hasUserOptionalLinks(links) {
+		prefs, err := p.getUserPreferences(post.UserId)
+		if err != nil && trace == nil {
+			p.API.LogError("Failed to load the preferences of the post author", "error", err.Error())
+		}
+		authorPrefs = prefs
+	}
+
+	if trace != nil {
+		for _, link := range links {
+			switch {
+			case link.Disabled:
+			case !link.IsActive(startTime):
+				trace.Skipped = append(trace.Skipped, autolink.TraceSkip{Link: link.DisplayName(), Reason: inactiveReason(link, startTime)})
+			case !p.inScope(link.Scope, channelName, teamName):
+				trace.Skipped = append(trace.Skipped, autolink.TraceSkip{Link: link.DisplayName(), Reason: "not in scope"})
+			case link.IsUserOptional() && authorPrefs.isDisabled(link.Name):
+				trace.Skipped = append(trace.Skipped, autolink.TraceSkip{Link: link.DisplayName(), Reason: "turned off by the author"})
+			}
+		}
+	}
+
 	var author *model.User
 	var authorErr *model.AppError
+	isBotAuthor := func() bool {
+		// Posts without an author, e.g. tested by a plugin, are not checked
+		if author == nil && authorErr == nil && post.UserId != "" {
+			author, authorErr = p.API.GetUser(post.UserId)
+			if authorErr != nil && trace == nil {
+				p.metrics.IncAuthorLookupErrors()
+				// NOTE: Not sure how we want to handle errors here, we can either:
+				// * assume that occasional rewrites of Bot messges are ok
+				// * assume that occasional not rewriting of all messages is ok
+				// Let's assume for now that former is a lesser evil and carry on.
+				p.API.LogError("failed to check if message for rewriting was send by a bot", "error", authorErr)
+			}
+		}
+		return author != nil && author.IsBot
+	}
+
+	// Reject links are matched against the whole message, code included
+	for _, link := range links {
+		if link.Action != autolink.ActionReject || !link.IsActive(startTime) || !p.inScope(link.Scope, channelName, teamName) {
+			continue
+		}
+		if link.IsUserOptional() && authorPrefs.isDisabled(link.Name) {
+			continue
+		}
+		reason, rejected := link.Rejection(post.Message)
+		if !rejected || (!link.ProcessesBotPosts() && isBotAuthor()) {
+			continue
+		}
+		if reason == "" {
+			reason = fmt.Sprintf("The post was rejected by the link %s.", link.DisplayName())
+		}
+		if trace != nil {
+			trace.Rejection = &autolink.TraceRejection{Link: link.DisplayName(), Reason: reason}
+		}
+		return rewrite{Message: post.Message, Rejection: reason}
+	}
+
+	// Redact links are applied whatever the time budget, so that nothing
+	// they mask gets through
+	hasRedactLinks := false
+	for _, link := range links {
+		if link.Action == autolink.ActionRedact {
+			hasRedactLinks = true
+			break
+		}
+	}
 
 	markdown.Inspect(post.Message, func(node interface{}) bool {
-		if node == nil {
+		if node == nil || (timedOut && !hasRedactLinks) {
 			return false
 		}
 
@@ -154,7 +359,9 @@ func (p *Plugin) ProcessPost(_ *plugin.Context, post *model.Post) (*model.Post,
 			// Do not process escaped links. Not exactly sure why but preserving the previous behavior.
 			// https://mattermost.atlassian.net/browse/MM-42669
 			if markdown.Unescape(toProcess) != toProcess {
-				p.API.LogDebug("skipping escaped autolink", "original", toProcess, "post_id", post.Id)
+				if trace == nil {
+					p.API.LogDebug("skipping escaped autolink", "original", toProcess, "post_id", post.Id)
+				}
 				return true
 			}
 
@@ -162,7 +369,9 @@ func (p *Plugin) ProcessPost(_ *plugin.Context, post *model.Post) (*model.Post,
 			start, end = node.Range.Position+offset, node.Range.End+offset
 			toProcess = message[start:end]
 			if node.Text != toProcess {
-				p.API.LogDebug("skipping text: parsed markdown did not match original", "parsed", node.Text, "original", toProcess, "post_id", post.Id)
+				if trace == nil {
+					p.API.LogDebug("skipping text: parsed markdown did not match original", "parsed", node.Text, "original", toProcess, "post_id", post.Id)
+				}
 				return true
 			}
 		}
@@ -172,35 +381,67 @@ func (p *Plugin) ProcessPost(_ *plugin.Context, post *model.Post) (*model.Post,
 		}
 
 		processed := toProcess
-		for _, link := range conf.Links {
-			if !p.inScope(link.Scope, channelName, teamName) {
+		var segment *autolink.TraceSegment
+		if trace != nil {
+			segment = &autolink.TraceSegment{Start: start - offset, End: end - offset, Text: toProcess}
+		}
+		for i, link := range links {
+			if link.Action == autolink.ActionReject || !link.IsActive(startTime) || !p.inScope(link.Scope, channelName, teamName) {
+				continue
+			}
+
+			if link.IsUserOptional() && authorPrefs.isDisabled(link.Name) {
+				continue
+			}
+
+			linkStartTime := time.Now()
+			if !link.IsMandatory() && (timedOut || (!deadline.IsZero() && linkStartTime.After(deadline))) {
+				timedOut = true
 				continue
 			}
 
 			out := link.Replace(processed)
+			elapsed := time.Since(linkStartTime)
+			if trace == nil {
+				p.metrics.ObserveLinkEvaluation(link.DisplayName(), elapsed)
+			}
+			if linkElapsed != nil {
+				linkElapsed[i] += elapsed
+			}
 			if out == processed {
 				continue
 			}
 
-			if !link.ProcessBotPosts {
-				if author == nil && authorErr == nil {
-					author, authorErr = p.API.GetUser(post.UserId)
-					if authorErr != nil {
-						// NOTE: Not sure how we want to handle errors here, we can either:
-						// * assume that occasional rewrites of Bot messges are ok
-						// * assume that occasional not rewriting of all messages is ok
-						// Let's assume for now that former is a lesser evil and carry on.
-						p.API.LogError("failed to check if message for rewriting was send by a bot", "error", authorErr)
-					}
-				}
+			var step *autolink.TraceStep
+			if segment != nil {
+				segment.Steps = append(segment.Steps, autolink.TraceStep{
+					Link:    link.DisplayName(),
+					Matches: link.Matches(processed),
+				})
+				step = &segment.Steps[len(segment.Steps)-1]
+			}
 
-				if author != nil && author.IsBot {
-					continue
+			if !link.ProcessesBotPosts() && isBotAuthor() {
+				if step != nil {
+					step.Skipped = "the author is a bot"
 				}
+				continue
 			}
 
+			if step != nil {
+				step.Result = out
+			}
+			if link.Action == autolink.ActionRedact {
+				if redactedMatches == nil {
+					redactedMatches = make([]int, len(links))
+				}
+				redactedMatches[i] += len(link.Matches(processed))
+			}
 			processed = out
 		}
+		if segment != nil && len(segment.Steps) > 0 {
+			trace.Segments = append(trace.Segments, *segment)
+		}
 
 		if toProcess != processed {
 			message = message[:start] + processed + message[end:]
@@ -211,11 +452,41 @@ func (p *Plugin) ProcessPost(_ *plugin.Context, post *model.Post) (*model.Post,
 		return true
 	})
 
-	if changed {
-		post.Message = message
-		post.Hashtags, _ = model.ParseHashtags(message)
+	if timedOut {
+		p.API.LogWarn("Post processing exceeded the time budget, remaining links were skipped",
+			"post_id", post.Id, "budget_ms", conf.ProcessingTimeBudget)
 	}
-	return post, ""
+	if linkElapsed != nil {
+		if tripped := p.recordLinkOverruns(links, linkElapsed, linkShare); len(tripped) > 0 {
+			go p.disableSlowLinks(tripped, linkShare)
+		}
+	}
+
+	redactions := []redaction{}
+	for i, n := range redactedMatches {
+		if n > 0 {
+			redactions = append(redactions, redaction{Link: links[i], Matches: n})
+		}
+	}
+	return rewrite{Message: message, Changed: changed, Redactions: redactions}
+}
+
+// inactiveReason explains why l is not active at t.
+func inactiveReason(l autolink.Autolink, t time.Time) string {
+	if l.ActiveFrom != nil && t.Before(*l.ActiveFrom) {
+		return "not active before " + l.ActiveFrom.Format(time.RFC3339)
+	}
+	return "expired at " + l.ActiveUntil.Format(time.RFC3339)
+}
+
+// effectiveLinks returns the links with the settings they inherit from their
+// group.
+func effectiveLinks(links []autolink.Autolink) []autolink.Autolink {
+	effective := make([]autolink.Autolink, len(links))
+	for i, l := range links {
+		effective[i] = l.Effective()
+	}
+	return effective
 }
 
 func (p *Plugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Request) {
@@ -225,16 +496,20 @@ func (p *Plugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Req
 // MessageWillBePosted is invoked when a message is posted by a user before it is committed
 // to the database.
 func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string) {
+	trustAutolinkProps(post, nil)
 	return p.ProcessPost(c, post)
 }
 
 // MessageWillBeUpdated is invoked when a message is updated by a user before it is committed
 // to the database.
-func (p *Plugin) MessageWillBeUpdated(c *plugin.Context, post *model.Post, _ *model.Post) (*model.Post, string) {
-	conf := p.getConfig()
-	if !conf.EnableOnUpdate {
-		return post, ""
+func (p *Plugin) MessageWillBeUpdated(_ *plugin.Context, post *model.Post, oldPost *model.Post) (*model.Post, string) {
+	// The post is updated by revertPost, its props are set by the plugin
+	if p.isReverting(post) {
+		return p.processPost(post, true)
 	}
+	trustAutolinkProps(post, oldPost)
 
-	return p.ProcessPost(c, post)
+	// The mandatory links are applied to every edit
+	mandatoryOnly := !p.getConfig().EnableOnUpdate || (oldPost != nil && (isReverted(oldPost) || isOptedOut(oldPost)))
+	return p.processPost(post, mandatoryOnly)
 }
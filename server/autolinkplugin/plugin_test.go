@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -645,7 +646,7 @@ func TestBotMessagesAreRewritenWhenConfigAllows(t *testing.T) {
 		Links: []autolink.Autolink{{
 			Pattern:         "(Mattermost)",
 			Template:        "[Mattermost](https://mattermost.com)",
-			ProcessBotPosts: true,
+			ProcessBotPosts: model.NewPointer(true),
 		}},
 	}
 
@@ -739,6 +740,7 @@ func TestHashtags(t *testing.T) {
 
 func TestAPI(t *testing.T) {
 	conf := Config{
+		AllowedPlugins: "somthing",
 		Links: []autolink.Autolink{{
 			Name:     "existing",
 			Pattern:  "thing",
@@ -763,6 +765,8 @@ func TestAPI(t *testing.T) {
 	api.On("GetChannel", mock.AnythingOfType("string")).Return(&testChannel, nil)
 	api.On("GetTeam", mock.AnythingOfType("string")).Return(&testTeam, nil)
 	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockAuditLog(api)
+	api.On("EnsureBotUser", mock.AnythingOfType("*model.Bot")).Return("botUserId", nil)
 
 	p := New()
 	p.SetAPI(api)
@@ -783,6 +787,52 @@ func TestAPI(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	assert.Len(t, p.conf.Links, 2)
 	assert.Equal(t, "new", p.conf.Links[1].Name)
+	assert.Equal(t, "somthing", p.conf.Links[1].OwnerPluginID)
+
+	// The plugins that are not listed are rejected
+	api.On("LogWarn", mock.AnythingOfType("string"), "plugin_id", "notallowed").Return()
+	recorder = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/v1/link", bytes.NewReader(jbyte))
+	require.NoError(t, err)
+	req.Header.Set("Mattermost-Plugin-ID", "notallowed")
+	p.ServeHTTP(&plugin.Context{}, recorder, req)
+	resp = recorder.Result()
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// When every plugin is allowed, they still only change their own links
+	p.UpdateConfig(func(conf *Config) {
+		conf.AllowAnyPlugin = true
+	})
+	for _, tc := range []struct {
+		link   string
+		status int
+	}{
+		{"new", http.StatusForbidden},
+		{"other", http.StatusOK},
+	} {
+		jbyte, err = json.Marshal(&autolink.Autolink{Name: tc.link, Pattern: tc.link + "pat", Template: "newtemp"})
+		require.NoError(t, err)
+		recorder = httptest.NewRecorder()
+		req, err = http.NewRequest("POST", "/api/v1/link", bytes.NewReader(jbyte))
+		require.NoError(t, err)
+		req.Header.Set("Mattermost-Plugin-ID", "notallowed")
+		p.ServeHTTP(&plugin.Context{}, recorder, req)
+		assert.Equal(t, tc.status, recorder.Result().StatusCode, tc.link)
+	}
+}
+
+func TestIsAuthorizedPlugin(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LogWarn", mock.AnythingOfType("string"), "plugin_id", "jira").Return()
+	p := New()
+	p.SetAPI(api)
+	assert.False(t, p.IsAuthorizedPlugin("jira"), "plugins are denied by default")
+
+	p.UpdateConfig(func(conf *Config) {
+		conf.AllowAnyPlugin = true
+	})
+	assert.True(t, p.IsAuthorizedPlugin("jira"))
 }
 
 func TestResolveScope(t *testing.T) {
@@ -1034,3 +1084,19 @@ func TestInScope(t *testing.T) {
 		assert.Equal(t, true, result)
 	})
 }
+
+func TestSubcommandsAreListed(t *testing.T) {
+	listed := subcommands()
+	for path := range autolinkCommandHandler.handlers {
+		name, _, _ := strings.Cut(path, "/")
+		assert.Contains(t, listed, name)
+	}
+
+	data := getAutoCompleteData()
+	assert.Equal(t, subcommandsDesc(), data.HelpText)
+	autocompleted := []string{}
+	for _, c := range data.SubCommands {
+		autocompleted = append(autocompleted, c.Trigger)
+	}
+	assert.ElementsMatch(t, listed, autocompleted)
+}
@@ -0,0 +1,103 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/presets"
+)
+
+// presetNameParam names the link added from a preset, instead of the default
+// name of the preset.
+const presetNameParam = "name"
+
+func executePresetList(_ *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 0 {
+		return responsef(helpText)
+	}
+
+	out := "###### Link presets\n"
+	for _, preset := range presets.List() {
+		out += fmt.Sprintf("- `%s`: %s\n", preset.Name, preset.Description)
+		for _, param := range preset.Params {
+			out += fmt.Sprintf("  - `%s`: %s", param.Name, param.Description)
+			if param.Default != "" {
+				out += fmt.Sprintf(" Defaults to `%s`.", param.Default)
+			}
+			out += "\n"
+		}
+	}
+	out += "\nAdd a link with `/autolink preset add <preset> key=value...`, e.g. " +
+		"`/autolink preset add jira baseURL=https://mycompany.atlassian.net projectKeys=MM,PLT`. " +
+		"Use `name=value` to name the link.\n"
+	return responsef("%s", out)
+}
+
+func executePresetAdd(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) < 1 {
+		return responsef(helpText)
+	}
+
+	preset, ok := presets.Get(args[0])
+	if !ok {
+		return responsef("There is no preset named %q, see `/autolink preset list`.", args[0])
+	}
+
+	params := map[string]string{}
+	name := ""
+	for _, arg := range args[1:] {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || key == "" {
+			return responsef("%q is not a key=value parameter", arg)
+		}
+		if _, dup := params[key]; dup || (key == presetNameParam && name != "") {
+			return responsef("parameter %q is set more than once", key)
+		}
+		if key == presetNameParam {
+			name = value
+			continue
+		}
+		params[key] = value
+	}
+
+	added, err := preset.Build(params)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	if name != "" {
+		added.Name = name
+	}
+
+	links := currentLinks(p)
+	if err = checkLinkName(links, -1, added.Name); err != nil {
+		return responsef("%v, set another name with `name=value`", err)
+	}
+
+	level, err := p.getPermissionLevel(header.UserId)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	// Like links added with `/autolink add`, links added by team and channel
+	// admins are limited to the current team or channel
+	if level < permissionEdit {
+		added.Scope, err = p.delegatedScope(header)
+		if err != nil {
+			return responsef("%v", err)
+		}
+	}
+	added.Owner = header.UserId
+	if err = authorizeLinkChange(p, header, "add", added); err != nil {
+		return responsef("%v", err)
+	}
+
+	err = saveConfigLinks(p, append(links, added))
+	if err != nil {
+		return responsef(err.Error())
+	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "add", nil, &added, auditResultSuccess)
+
+	return executeList(p, c, header, added.Name)
+}
@@ -0,0 +1,92 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestPresetCommands(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(MM-\\d+)",
+			Template: "https://jira/$1",
+		}},
+	}
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	getAuditLog := mockAuditLog(mockAPI)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:  "adminId",
+			Command: command,
+		})
+		return resp.Text
+	}
+
+	t.Run("list", func(t *testing.T) {
+		text := run("/autolink preset list")
+		assert.Contains(t, text, "- `jira`: Jira issue keys")
+		assert.Contains(t, text, "  - `projectKeys`: The keys of the projects")
+		assert.Contains(t, text, "Defaults to `https://github.com`.")
+		assert.Contains(t, text, "- `masking:visa`: ")
+	})
+
+	t.Run("add", func(t *testing.T) {
+		text := run("/autolink preset add masking:visa")
+		assert.Contains(t, text, "Visa")
+
+		links := p.getConfig().Links
+		require.Len(t, links, 2)
+		assert.Equal(t, "Visa", links[1].Name)
+		assert.Equal(t, "adminId", links[1].Owner)
+		assert.False(t, links[1].Disabled)
+		assert.Contains(t, links[1].Pattern, "LastFour")
+
+		events := getAuditLog()
+		require.NotEmpty(t, events)
+		assert.Equal(t, "add", events[len(events)-1].Action)
+		assert.Equal(t, "Visa", events[len(events)-1].Link)
+	})
+
+	t.Run("add with parameters and a name", func(t *testing.T) {
+		run(`/autolink preset add jira "baseURL=https://mattermost.atlassian.net" projectKeys=MM,PLT "name=Jira Cloud"`)
+
+		links := p.getConfig().Links
+		require.Len(t, links, 3)
+		assert.Equal(t, "Jira Cloud", links[2].Name)
+		assert.Equal(t, "[${key}](https://mattermost.atlassian.net/browse/${key})", links[2].Template)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Equal(t, `link 1 is already named "Jira", set another name with `+"`name=value`",
+			run("/autolink preset add jira baseURL=https://jira.example.com projectKeys=MM"))
+		assert.Equal(t, `There is no preset named "zendesk", see `+"`/autolink preset list`.",
+			run("/autolink preset add zendesk"))
+		assert.Equal(t, `"org" is not a key=value parameter`, run("/autolink preset add github org"))
+		assert.Equal(t, `parameter "org" is set more than once`, run("/autolink preset add github org=a org=b"))
+		assert.Contains(t, run("/autolink preset add github"), "preset github requires org")
+		assert.Len(t, p.getConfig().Links, 3)
+	})
+}
@@ -0,0 +1,70 @@
+package autolinkplugin
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// redaction is what a redact link masked in a post.
+type redaction struct {
+	Link    autolink.Autolink
+	Matches int
+}
+
+// reportRedactions tells the author of post what was redacted from it, and
+// records the redactions of the links that audit them. The audit log is
+// saved in the background, not to hold up the post.
+func (p *Plugin) reportRedactions(post *model.Post, redactions []redaction) {
+	audited := []autolink.Autolink{}
+	for _, r := range redactions {
+		if r.Link.AuditRedactions {
+			audited = append(audited, r.Link)
+		}
+	}
+	if len(audited) > 0 {
+		go p.auditRedactions(post.UserId, post.ChannelId, audited)
+	}
+
+	// Posts without an author, e.g. created by a plugin, have no one to tell
+	if post.UserId == "" {
+		return
+	}
+	p.API.SendEphemeralPost(post.UserId, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: post.ChannelId,
+		Message:   formatRedactions(redactions),
+	})
+}
+
+// auditRedactions records that links redacted a post of userID in
+// channelID.
+func (p *Plugin) auditRedactions(userID, channelID string, links []autolink.Autolink) {
+	for _, l := range links {
+		p.audit(AuditEvent{
+			Source:    auditSourcePlugin,
+			UserID:    userID,
+			Action:    autolink.ActionRedact,
+			Link:      l.DisplayName(),
+			ChannelID: channelID,
+			Result:    auditResultSuccess,
+		})
+	}
+}
+
+// formatRedactions explains to the author of a post what was redacted from
+// it.
+func formatRedactions(redactions []redaction) string {
+	message := "Parts of your message were redacted before it was posted, because they look like data that must not be shared here:\n"
+	for _, r := range redactions {
+		matches := "1 match"
+		if r.Matches > 1 {
+			matches = fmt.Sprintf("%d matches", r.Matches)
+		}
+		message += fmt.Sprintf("- **%s**: %s\n", r.Link.DisplayName(), matches)
+	}
+	message += "The original message was not kept. If this is a mistake, ask a plugin admin to review the link.\n"
+	return message
+}
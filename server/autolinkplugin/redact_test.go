@@ -0,0 +1,135 @@
+package autolinkplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestRedact(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		EnableEscapePrefix: true,
+		OptOutDirective:    "!nolink",
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(?P<key>MM-\\d+)",
+			Template: "[$key](https://jira/$key)",
+		}, {
+			Name:            "Visa",
+			Pattern:         "(?P<part1>4\\d{3})-(?P<part2>\\d{4})-(?P<part3>\\d{4})-(?P<LastFour>\\d{4})",
+			Template:        "VISA XXXX-XXXX-XXXX-$LastFour",
+			Action:          autolink.ActionRedact,
+			AuditRedactions: true,
+		}, {
+			Name:     "SSN",
+			Pattern:  "(?P<part1>\\d{3})-(?P<part2>\\d{2})-(?P<LastFour>\\d{4})",
+			Template: "XXX-XX-$LastFour",
+			Action:   autolink.ActionRedact,
+		}},
+	}
+
+	var ephemeral []*model.Post
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	mockAPI.On("GetChannel", "channelId").Return(&model.Channel{Id: "channelId", Name: "town-square", TeamId: "teamId"}, nil)
+	mockAPI.On("GetTeam", "teamId").Return(&model.Team{Id: "teamId", Name: "eng"}, nil)
+	mockAPI.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockAPI.On("SendEphemeralPost", "userId", mock.AnythingOfType("*model.Post")).Return(func(_ string, post *model.Post) *model.Post {
+		ephemeral = append(ephemeral, post)
+		return post
+	})
+	getAuditLog := mockAuditLog(mockAPI)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	t.Run("redacted posts are not kept to be reverted", func(t *testing.T) {
+		ephemeral = nil
+		post, _ := p.ProcessPost(nil, &model.Post{
+			UserId:    "userId",
+			ChannelId: "channelId",
+			Message:   "MM-1 paid with 4111-1111-1111-1234 and 4111-2222-2222-5678, SSN 652-47-3356",
+			Props:     model.StringInterface{propOriginalMessage: "from a previous edit"},
+		})
+		assert.Equal(t, "[MM-1](https://jira/MM-1) paid with VISA XXXX-XXXX-XXXX-1234 and VISA XXXX-XXXX-XXXX-5678, SSN XXX-XX-3356", post.Message)
+		assert.Nil(t, post.GetProp(propOriginalMessage))
+
+		require.Len(t, ephemeral, 1)
+		assert.Equal(t, "channelId", ephemeral[0].ChannelId)
+		assert.Equal(t, "Parts of your message were redacted before it was posted, because they look like data that must not be shared here:\n"+
+			"- **Visa**: 2 matches\n"+
+			"- **SSN**: 1 match\n"+
+			"The original message was not kept. If this is a mistake, ask a plugin admin to review the link.\n",
+			ephemeral[0].Message)
+		assert.NotContains(t, ephemeral[0].Message, "1111")
+	})
+
+	t.Run("only the links that audit redactions are recorded", func(t *testing.T) {
+		require.Eventually(t, func() bool { return len(getAuditLog()) > 0 }, time.Second, time.Millisecond)
+		events := getAuditLog()
+		require.Len(t, events, 1)
+		assert.Equal(t, auditSourcePlugin, events[0].Source)
+		assert.Equal(t, "redact", events[0].Action)
+		assert.Equal(t, "Visa", events[0].Link)
+		assert.Equal(t, "userId", events[0].UserID)
+		assert.Equal(t, "channelId", events[0].ChannelID)
+		assert.Contains(t, events[0].ToMarkdown(), "**redact** `Visa` in channel `channelId` by user `userId` via autolink_plugin\n")
+	})
+
+	t.Run("the escape prefix, the opt-outs and edits don't prevent redaction", func(t *testing.T) {
+		post, _ := p.ProcessPost(nil, &model.Post{UserId: "userId", ChannelId: "channelId", Message: "SSN \\652-47-3356 MM-1"})
+		assert.Equal(t, "SSN \\XXX-XX-3356 [MM-1](https://jira/MM-1)", post.Message)
+
+		post, _ = p.ProcessPost(nil, &model.Post{UserId: "userId", ChannelId: "channelId", Message: "!nolink SSN 652-47-3356 MM-1"})
+		assert.Equal(t, "SSN XXX-XX-3356 MM-1", post.Message)
+		assert.Equal(t, true, post.GetProp(propOptedOut))
+
+		for _, prop := range []string{propOptedOut, propReverted} {
+			old := &model.Post{UserId: "userId", ChannelId: "channelId", Message: "MM-1"}
+			old.AddProp(prop, true)
+			post, _ = p.MessageWillBeUpdated(nil, &model.Post{UserId: "userId", ChannelId: "channelId", Message: "SSN 652-47-3356 MM-1"}, old)
+			assert.Equal(t, "SSN XXX-XX-3356 MM-1", post.Message, prop)
+		}
+
+		// The plugin is not applied to updated posts
+		post, _ = p.MessageWillBeUpdated(nil, &model.Post{UserId: "userId", ChannelId: "channelId", Message: "SSN 652-47-3356 MM-1"}, &model.Post{})
+		assert.Equal(t, "SSN XXX-XX-3356 MM-1", post.Message)
+	})
+
+	t.Run("other links keep the original", func(t *testing.T) {
+		ephemeral = nil
+		post, _ := p.ProcessPost(nil, &model.Post{UserId: "userId", ChannelId: "channelId", Message: "MM-1"})
+		assert.Equal(t, "MM-1", post.GetProp(propOriginalMessage))
+		assert.Empty(t, ephemeral)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		run := func(command string) string {
+			resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{UserId: "adminId", ChannelId: "channelId", Command: command})
+			return resp.Text
+		}
+
+		assert.Contains(t, run("/autolink set Jira Action hide"), `"hide" is not a valid action`)
+		assert.Empty(t, p.getConfig().Links[0].Action)
+		run("/autolink set Jira Action redact")
+		assert.Equal(t, autolink.ActionRedact, p.getConfig().Links[0].Action)
+		run(`/autolink set Jira Action ""`)
+		assert.Empty(t, p.getConfig().Links[0].Action)
+	})
+}
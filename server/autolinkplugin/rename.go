@@ -0,0 +1,132 @@
+package autolinkplugin
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// checkLinkName returns an error if name can't be given to the link at index
+// n of links, because another link has it or it would be read as a link
+// number or a selector. n is -1 for a new link.
+func checkLinkName(links []autolink.Autolink, n int, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("the name of a link can't be empty")
+	}
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil || isLinkSelector(name) {
+		return errors.Errorf("%q can't be used as a name, it would be read as a link number or a selector", name)
+	}
+	for i, l := range links {
+		if i != n && strings.EqualFold(l.Name, name) {
+			return errors.Errorf("link %d is already named %q", i+1, l.Name)
+		}
+	}
+	return nil
+}
+
+func executeClone(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 2 {
+		return responsef(helpText)
+	}
+
+	links, refs, err := searchLinkRef(p, true, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	name := args[1]
+	if err = checkLinkName(links, -1, name); err != nil {
+		return responsef("%v", err)
+	}
+
+	// The clone is a new link of the user who made it, disabled until it is
+	// changed so that it doesn't apply twice
+	clone := links[refs[0]]
+	clone.Name = name
+	clone.Scope = append([]string(nil), clone.Scope...)
+	clone.Owner = header.UserId
+	clone.OwnerPluginID = ""
+	clone.Disabled = true
+	if err = authorizeLinkChange(p, header, "add", clone); err != nil {
+		return responsef("%v", err)
+	}
+
+	err = saveConfigLinks(p, append(links, clone))
+	if err != nil {
+		return responsef(err.Error())
+	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "add", nil, &clone, auditResultSuccess)
+
+	return executeList(p, c, header, name)
+}
+
+func executeRename(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 2 {
+		return responsef(helpText)
+	}
+
+	links, refs, err := searchLinkRef(p, true, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	n := refs[0]
+	name := args[1]
+	if err = checkLinkName(links, n, name); err != nil {
+		return responsef("%v", err)
+	}
+
+	before := links[n]
+	if err = authorizeLinkChange(p, header, "rename", before); err != nil {
+		return responsef("%v", err)
+	}
+	links[n].Name = name
+
+	err = saveConfigLinks(p, links)
+	if err != nil {
+		return responsef(err.Error())
+	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "rename", &before, &links[n], auditResultSuccess)
+	p.renameUserPreferences(before.Name, name)
+
+	return executeList(p, c, header, strconv.Itoa(n+1))
+}
+
+func executeMove(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 2 {
+		return responsef(helpText)
+	}
+
+	links, refs, err := searchLinkRef(p, true, args[0])
+	if err != nil {
+		return responsef("%v", err)
+	}
+	from := refs[0]
+	to, err := strconv.Atoi(args[1])
+	if err != nil || to < 1 || to > len(links) {
+		return responsef("%q is not a valid position, it must be between 1 and %d", args[1], len(links))
+	}
+	to--
+
+	moved := links[from]
+	if err = authorizeLinkChange(p, header, "move", moved); err != nil {
+		return responsef("%v", err)
+	}
+	if from == to {
+		return executeList(p, c, header)
+	}
+
+	links = append(links[:from], links[from+1:]...)
+	links = append(links[:to], append([]autolink.Autolink{moved}, links[to:]...)...)
+
+	err = saveConfigLinks(p, links)
+	if err != nil {
+		return responsef(err.Error())
+	}
+	p.auditLinkChange(auditSourceCommand, header.UserId, "", "move", &moved, &moved, auditResultSuccess)
+
+	return executeList(p, c, header)
+}
@@ -0,0 +1,154 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestCheckLinkName(t *testing.T) {
+	links := []autolink.Autolink{{Name: "Jira"}, {Name: "GitHub"}}
+
+	assert.NoError(t, checkLinkName(links, -1, "Zendesk"))
+	assert.NoError(t, checkLinkName(links, 0, "JIRA"))
+	assert.EqualError(t, checkLinkName(links, -1, "jira"), `link 1 is already named "Jira"`)
+	assert.EqualError(t, checkLinkName(links, 0, "github"), `link 2 is already named "GitHub"`)
+	assert.Error(t, checkLinkName(links, -1, " "))
+	assert.Error(t, checkLinkName(links, -1, "3"))
+	assert.Error(t, checkLinkName(links, -1, "1-2"))
+	assert.Error(t, checkLinkName(links, -1, "name:Jira"))
+}
+
+func TestLinkOrderCommands(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:     "Zendesk",
+			Pattern:  "(ZD-\\d+)",
+			Template: "https://zendesk/$1",
+			Scope:    []string{"sales"},
+		}, {
+			Name:          "Jira",
+			Pattern:       "(MM-\\d+)",
+			Template:      "https://jira/$1",
+			OwnerPluginID: "jira",
+		}, {
+			Name:     "GitHub",
+			Pattern:  "(#\\d+)",
+			Template: "https://github/$1",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	api.On("KVList", 0, userPreferencesPageSize).Return([]string{"other", userPreferencesKeyPrefix + "userId"}, nil)
+	prefs := []byte(`{"disabled_links":["GitHub","Jira2"]}`)
+	api.On("KVGet", userPreferencesKeyPrefix+"userId").Return(func(string) []byte { return prefs }, nil)
+	api.On("KVSet", userPreferencesKeyPrefix+"userId", mock.Anything).Return(func(_ string, data []byte) *model.AppError {
+		prefs = data
+		return nil
+	})
+	getAuditLog := mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:  "adminId",
+			Command: command,
+		})
+		return resp.Text
+	}
+	names := func() []string {
+		names := []string{}
+		for _, l := range p.getConfig().Links {
+			names = append(names, l.Name)
+		}
+		return names
+	}
+
+	t.Run("list follows the processing order", func(t *testing.T) {
+		text := run("/autolink list")
+		assert.Regexp(t, `(?s)1.*Zendesk.*2.*Jira.*3.*GitHub`, text)
+		assert.Contains(t, run("/autolink list 1"), "Zendesk")
+
+		run("/autolink list")
+		assert.Equal(t, []string{"Zendesk", "Jira", "GitHub"}, names())
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		text := run("/autolink clone Jira Jira2")
+		assert.Contains(t, text, "Jira2")
+
+		links := p.getConfig().Links
+		require.Len(t, links, 4)
+		clone := links[3]
+		assert.Equal(t, "Jira2", clone.Name)
+		assert.Equal(t, links[1].Pattern, clone.Pattern)
+		assert.Equal(t, links[1].Template, clone.Template)
+		assert.True(t, clone.Disabled)
+		assert.Equal(t, "adminId", clone.Owner)
+		assert.Empty(t, clone.OwnerPluginID)
+
+		assert.Contains(t, run("/autolink clone Jira github"), `link 3 is already named "GitHub"`)
+		assert.Len(t, p.getConfig().Links, 4)
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		run("/autolink rename Jira2 JiraCloud")
+		assert.Equal(t, []string{"Zendesk", "Jira", "GitHub", "JiraCloud"}, names())
+
+		assert.Contains(t, run("/autolink rename JiraCloud Zendesk"), `link 1 is already named "Zendesk"`)
+		assert.Contains(t, run("/autolink rename JiraCloud 7"), "would be read as a link number")
+		assert.Equal(t, "JiraCloud", p.getConfig().Links[3].Name)
+
+		events := getAuditLog()
+		event := events[len(events)-1]
+		assert.Equal(t, "rename", event.Action)
+		assert.Equal(t, []FieldChange{{Field: "Name", Old: "Jira2", New: "JiraCloud"}}, event.Changes)
+
+		// The users who turned the link off keep it off
+		assert.JSONEq(t, `{"disabled_links":["GitHub","JiraCloud"]}`, string(prefs))
+	})
+
+	t.Run("set Name", func(t *testing.T) {
+		assert.Contains(t, run("/autolink set JiraCloud Name Zendesk"), `link 1 is already named "Zendesk"`)
+		assert.Contains(t, run("/autolink set JiraCloud Name 2-3"), "would be read as a link number or a selector")
+		assert.Contains(t, run("/autolink set 1-2 Name Jira"), "rename them one at a time")
+		assert.Equal(t, []string{"Zendesk", "Jira", "GitHub", "JiraCloud"}, names())
+
+		run("/autolink set GitHub Name GitHubCom")
+		assert.Equal(t, []string{"Zendesk", "Jira", "GitHubCom", "JiraCloud"}, names())
+		assert.JSONEq(t, `{"disabled_links":["JiraCloud","GitHubCom"]}`, string(prefs))
+
+		run("/autolink set GitHubCom Name GitHub")
+		assert.Equal(t, []string{"Zendesk", "Jira", "GitHub", "JiraCloud"}, names())
+	})
+
+	t.Run("move", func(t *testing.T) {
+		run("/autolink move JiraCloud 1")
+		assert.Equal(t, []string{"JiraCloud", "Zendesk", "Jira", "GitHub"}, names())
+
+		run("/autolink move 2 4")
+		assert.Equal(t, []string{"JiraCloud", "Jira", "GitHub", "Zendesk"}, names())
+
+		assert.Contains(t, run("/autolink move Jira 5"), "not a valid position")
+		assert.Contains(t, run("/autolink move Jira first"), "not a valid position")
+		assert.Equal(t, []string{"JiraCloud", "Jira", "GitHub", "Zendesk"}, names())
+	})
+}
@@ -0,0 +1,118 @@
+package autolinkplugin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// propOriginalMessage holds the text of a post as it was before the
+	// plugin last rewrote it.
+	propOriginalMessage = "autolink_original_message"
+
+	// propReverted marks a post whose rewrite was reverted by its author or
+	// an admin. Such posts are never processed again.
+	propReverted = "autolink_reverted"
+)
+
+func isReverted(post *model.Post) bool {
+	reverted, _ := post.GetProp(propReverted).(bool)
+	return reverted
+}
+
+// autolinkProps are the props the plugin sets on posts.
+var autolinkProps = []string{propOriginalMessage, propReverted, propOptedOut}
+
+// trustAutolinkProps replaces the props the plugin sets on post with those of
+// saved, the post as it is stored, or removes them if saved is nil. Clients
+// can set any prop, so these are never taken from them.
+func trustAutolinkProps(post, saved *model.Post) {
+	for _, key := range autolinkProps {
+		var value interface{}
+		if saved != nil {
+			value = saved.GetProp(key)
+		}
+		post.DelProp(key)
+		if value != nil {
+			post.AddProp(key, value)
+		}
+	}
+}
+
+// isReverting reports whether post is the update made by revertPost. Other
+// edits of the post made at the same time don't have the same message.
+func (p *Plugin) isReverting(post *model.Post) bool {
+	p.revertingLock.Lock()
+	defer p.revertingLock.Unlock()
+
+	message, ok := p.reverting[post.Id]
+	return ok && message == post.Message && isReverted(post)
+}
+
+// revertPost restores the original message of a post that was rewritten by
+// the plugin, and marks it so that future edits are left alone. Only the
+// author of the post or a plugin admin may revert it. The original message is
+// still redacted and rejected by the mandatory links.
+func (p *Plugin) revertPost(userID, postID string) (*model.Post, error) {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get post `%s`", postID)
+	}
+
+	if post.UserId != userID {
+		isAdmin, err := p.IsAuthorizedAdmin(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isAdmin {
+			return nil, errors.New("only the author of a post or a plugin admin can revert it")
+		}
+	}
+
+	original, ok := post.GetProp(propOriginalMessage).(string)
+	if !ok {
+		return nil, errors.Errorf("post `%s` was not changed by the autolink plugin", postID)
+	}
+
+	post.Message = original
+	result := p.replaceLinks(post, p.getConfig().Mandatory(), time.Now(), nil)
+	if result.Rejection != "" {
+		return nil, errors.Errorf("the original message of post `%s` can't be restored: %s", postID, result.Rejection)
+	}
+
+	post.Message = result.Message
+	post.Hashtags, _ = model.ParseHashtags(result.Message)
+	post.DelProp(propOriginalMessage)
+	post.AddProp(propReverted, true)
+
+	p.revertingLock.Lock()
+	p.reverting[post.Id] = post.Message
+	p.revertingLock.Unlock()
+	defer func() {
+		p.revertingLock.Lock()
+		delete(p.reverting, post.Id)
+		p.revertingLock.Unlock()
+	}()
+
+	updated, appErr := p.API.UpdatePost(post)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to update post `%s`", postID)
+	}
+	if len(result.Redactions) > 0 {
+		p.reportRedactions(updated, result.Redactions)
+	}
+
+	return updated, nil
+}
+
+// parsePostID accepts either a post ID or a permalink to a post.
+func parsePostID(ref string) string {
+	ref = strings.TrimRight(ref, "/")
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		ref = ref[i+1:]
+	}
+	return ref
+}
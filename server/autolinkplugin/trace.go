@@ -0,0 +1,109 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/api"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// optAll makes `/autolink test` apply all the enabled links.
+const optAll = "--all"
+
+// traceLinks applies all the enabled links to message, as if userID posted it
+// in channelID, and returns the trace of every step.
+func (p *Plugin) traceLinks(userID, channelID, message string) *autolink.Trace {
+	trace := &autolink.Trace{Message: message}
+	post := &model.Post{
+		UserId:    userID,
+		ChannelId: channelID,
+		Message:   message,
+	}
+	trace.Result = p.replaceLinks(post, p.getConfig(), time.Now(), trace).Message
+	return trace
+}
+
+// TestLinks applies all the enabled links to message, as if userID posted it
+// in channelID, for the REST API. Users need to be allowed to run
+// `/autolink test`, plugins need to be allowed to use the API.
+func (p *Plugin) TestLinks(userID, channelID, message string) (*autolink.Trace, error) {
+	if userID != "" {
+		level, err := p.getPermissionLevel(userID)
+		if err != nil {
+			return nil, err
+		}
+		if level < commandPermissions["test"] && !p.isDelegatedAdmin(&model.CommandArgs{UserId: userID, ChannelId: channelID}) {
+			return nil, api.ErrNotAuthorized
+		}
+	}
+	return p.traceLinks(userID, channelID, message), nil
+}
+
+func executeTestAll(p *Plugin, header *model.CommandArgs, message string) *model.CommandResponse {
+	return responsef("%s", formatTrace(p.traceLinks(header.UserId, header.ChannelId, message)))
+}
+
+// formatTrace prints a trace as markdown.
+func formatTrace(trace *autolink.Trace) string {
+	out := fmt.Sprintf("- Original: `%s`\n", trace.Message)
+	for _, skip := range trace.Skipped {
+		out += fmt.Sprintf("- Link %s: skipped, %s\n", skip.Link, skip.Reason)
+	}
+	if trace.Rejection != nil {
+		out += fmt.Sprintf("- Rejected by link %s: %s\n", trace.Rejection.Link, trace.Rejection.Reason)
+	} else if len(trace.Segments) == 0 {
+		out += "- No link matched\n"
+	}
+
+	for _, segment := range trace.Segments {
+		out += fmt.Sprintf("- Text `%s` (bytes %d-%d):\n", segment.Text, segment.Start, segment.End)
+		for i, step := range segment.Steps {
+			out += fmt.Sprintf("  %d. Link %s matched %s\n", i+1, step.Link, formatMatches(step.Matches))
+			if step.Skipped != "" {
+				out += fmt.Sprintf("     - skipped, %s\n", step.Skipped)
+			} else {
+				out += fmt.Sprintf("     - changed to `%s`\n", step.Result)
+			}
+		}
+	}
+
+	out += fmt.Sprintf("- Result: `%s`\n", trace.Result)
+	return out
+}
+
+// formatMatches lists matches on one line, with their byte ranges and
+// captured groups.
+func formatMatches(matches []autolink.Match) string {
+	list := []string{}
+	for _, m := range matches {
+		list = append(list, formatMatch(m))
+	}
+	return strings.Join(list, ", ")
+}
+
+// formatMatch prints a match with its byte range and captured groups.
+func formatMatch(m autolink.Match) string {
+	text := fmt.Sprintf("`%s` (bytes %d-%d)", m.Text, m.Start, m.End)
+	if groups := formatGroups(m.Groups); groups != "" {
+		text += " with " + groups
+	}
+	return text
+}
+
+// formatGroups lists the captured groups, by name and by number as they can
+// be used in a template.
+func formatGroups(groups []autolink.Group) string {
+	list := []string{}
+	for _, g := range groups {
+		name := fmt.Sprintf("`$%d`", g.Index)
+		if g.Name != "" {
+			name = fmt.Sprintf("`$%s`/%s", g.Name, name)
+		}
+		list = append(list, fmt.Sprintf("%s=`%s`", name, g.Value))
+	}
+	return strings.Join(list, " ")
+}
@@ -0,0 +1,176 @@
+package autolinkplugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/api"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestTraceLinks(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(?P<key>MM-\\d+)",
+			Template: "$key (jira)",
+		}, {
+			Name:     "Disabled",
+			Pattern:  "(jira)",
+			Template: "nope",
+			Disabled: true,
+		}, {
+			Name:            "Jira tag",
+			Pattern:         "\\((jira)\\)",
+			Template:        "[JIRA]",
+			ProcessBotPosts: model.NewPointer(true),
+		}, {
+			Name:     "Sales",
+			Pattern:  "(MM-\\d+)",
+			Template: "sales",
+			Scope:    []string{"sales"},
+		}},
+	}
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("GetUser", "botId").Return(&model.User{Id: "botId", IsBot: true}, nil)
+	mockAPI.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	mockAPI.On("GetChannel", "channelId").Return(&model.Channel{Id: "channelId", Name: "town-square", TeamId: "teamId"}, nil)
+	mockAPI.On("GetTeam", "teamId").Return(&model.Team{Id: "teamId", Name: "eng"}, nil)
+	mockAPI.On("GetUser", "ghostId").Return(nil, model.NewAppError("GetUser", "not found", nil, "", 404))
+	mockAPI.On("GetChannel", "ghostChannelId").Return(nil, model.NewAppError("GetChannel", "not found", nil, "", 404))
+	mockAPI.On("HasPermissionToChannel", "userId", "channelId", model.PermissionManageChannelRoles).Return(false)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	t.Run("command", func(t *testing.T) {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:    "adminId",
+			ChannelId: "channelId",
+			Command:   "/autolink test --all See MM-1 and `MM-2`",
+		})
+		assert.Equal(t, "- Original: `See MM-1 and `MM-2``\n"+
+			"- Link Sales: skipped, not in scope\n"+
+			"- Text `See MM-1 and ` (bytes 0-13):\n"+
+			"  1. Link Jira matched `MM-1` (bytes 4-8) with `$key`/`$3`=`MM-1`\n"+
+			"     - changed to `See MM-1 (jira) and `\n"+
+			"  2. Link Jira tag matched `(jira)` (bytes 9-15) with `$3`=`jira`\n"+
+			"     - changed to `See MM-1 [JIRA] and `\n"+
+			"- Result: `See MM-1 [JIRA] and `MM-2``\n", resp.Text)
+	})
+
+	t.Run("bot", func(t *testing.T) {
+		trace := p.traceLinks("botId", "channelId", "MM-1")
+		assert.Equal(t, "MM-1", trace.Result)
+		require.Len(t, trace.Segments, 1)
+		require.Len(t, trace.Segments[0].Steps, 1)
+		assert.Equal(t, "Jira", trace.Segments[0].Steps[0].Link)
+		assert.Equal(t, "the author is a bot", trace.Segments[0].Steps[0].Skipped)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		trace := p.traceLinks("adminId", "channelId", "nothing")
+		assert.Empty(t, trace.Segments)
+		assert.Contains(t, formatTrace(trace), "- No link matched\n")
+	})
+
+	t.Run("REST", func(t *testing.T) {
+		trace, err := p.TestLinks("", "channelId", "MM-1")
+		require.NoError(t, err)
+		assert.Equal(t, "MM-1 [JIRA]", trace.Result)
+
+		_, err = p.TestLinks("userId", "channelId", "MM-1")
+		assert.Equal(t, api.ErrNotAuthorized, err)
+	})
+
+	t.Run("no metrics or logs", func(t *testing.T) {
+		// The failed lookups are not logged, LogError is not mocked
+		trace := p.traceLinks("ghostId", "ghostChannelId", "MM-1")
+		assert.Equal(t, "MM-1 [JIRA]", trace.Result)
+
+		b := &strings.Builder{}
+		_, err := p.metrics.WriteTo(b)
+		require.NoError(t, err)
+		out := b.String()
+		assert.Contains(t, out, "autolink_link_evaluation_duration_seconds_count{link=\"Jira\"} 0\n")
+		assert.Contains(t, out, "autolink_scope_resolution_errors_total 0\n")
+		assert.Contains(t, out, "autolink_author_lookup_errors_total 0\n")
+	})
+}
+
+func TestTestCommand(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand:       true,
+		EnableEscapePrefix:       true,
+		Viewers:                  viewersEveryone,
+		HideTemplatesFromViewers: true,
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(?P<project>MM)-(\\d+)",
+			Template: "[$project-$4](https://jira/$project-$4)",
+		}, {
+			Name:      "Hashtag",
+			Pattern:   "tag-(\\w+)",
+			Template:  "#$1",
+			WordMatch: true,
+		}},
+	}
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("GetUser", "viewerId").Return(&model.User{Id: "viewerId", Roles: "system_user"}, nil)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(userID, command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:  userID,
+			Command: command,
+		})
+		return resp.Text
+	}
+
+	assert.Equal(t, "- Original: `see MM-12, \\MM-3 and MM-4`\n"+
+		"- Link Jira: changed to `see [MM-12](https://jira/MM-12), MM-3 and [MM-4](https://jira/MM-4)`\n"+
+		"  - Effective pattern: `(?P<MattermostNonWordPrefix>(^|\\s|\\\\))(?P<project>MM)-(\\d+)(?P<MattermostNonWordSuffix>$|[\\s\\.\\!\\?\\,\\)])`\n"+
+		"  - Match `MM-12` (bytes 4-9) with `$project`/`$3`=`MM` `$4`=`12`\n"+
+		"  - Match `MM-4` (bytes 21-25) with `$project`/`$3`=`MM` `$4`=`4`\n",
+		run("adminId", "/autolink test Jira see MM-12, \\MM-3 and MM-4"))
+
+	assert.Equal(t, "- Original: `tag-go and tag-rust`\n"+
+		"- Link Hashtag: changed to `#go and #rust`\n"+
+		"  - Effective pattern: `\\btag-(\\w+)\\b`\n"+
+		"  - Match `tag-go` (bytes 0-6) with `$1`=`go`\n"+
+		"  - Match `tag-rust` (bytes 11-19) with `$1`=`rust`\n",
+		run("adminId", "/autolink test Hashtag tag-go and tag-rust"))
+
+	text := run("viewerId", "/autolink test Jira MM-1")
+	assert.NotContains(t, text, "Effective pattern")
+	assert.Contains(t, text, "- Match `MM-1` (bytes 0-4)")
+
+	assert.Contains(t, run("adminId", "/autolink test Jira nothing"), "- Link Jira: _no change_\n  - Effective pattern:")
+}
@@ -0,0 +1,214 @@
+package autolinkplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+const (
+	userPreferencesKeyPrefix = "user_preferences_"
+
+	// userPreferencesPageSize is the number of keys listed at a time to
+	// find the preferences of all users.
+	userPreferencesPageSize = 100
+)
+
+// UserPreferences are the per-user autolink settings, stored in the KV store.
+type UserPreferences struct {
+	// DisabledLinks are the names of UserOptional links that are not
+	// applied to the user's posts.
+	DisabledLinks []string `json:"disabled_links"`
+}
+
+func (prefs *UserPreferences) isDisabled(name string) bool {
+	if prefs == nil {
+		return false
+	}
+	for _, disabled := range prefs.DisabledLinks {
+		if disabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (prefs *UserPreferences) setDisabled(name string, disabled bool) {
+	kept := []string{}
+	for _, n := range prefs.DisabledLinks {
+		if n != name {
+			kept = append(kept, n)
+		}
+	}
+	if disabled {
+		kept = append(kept, name)
+	}
+	prefs.DisabledLinks = kept
+}
+
+func (p *Plugin) getUserPreferences(userID string) (*UserPreferences, error) {
+	data, appErr := p.API.KVGet(userPreferencesKeyPrefix + userID)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to load preferences of user `%s`", userID)
+	}
+
+	prefs := &UserPreferences{}
+	if data == nil {
+		return prefs, nil
+	}
+	if err := json.Unmarshal(data, prefs); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode preferences of user `%s`", userID)
+	}
+	return prefs, nil
+}
+
+func (p *Plugin) saveUserPreferences(userID string, prefs *UserPreferences) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(userPreferencesKeyPrefix+userID, data); appErr != nil {
+		return errors.Wrapf(appErr, "failed to save preferences of user `%s`", userID)
+	}
+	return nil
+}
+
+// renameUserPreferences changes the name of a link from oldName to newName
+// in the preferences of the users who turned it off. Failures are logged, the
+// link is renamed anyway.
+func (p *Plugin) renameUserPreferences(oldName, newName string) {
+	if oldName == "" || oldName == newName {
+		return
+	}
+
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, userPreferencesPageSize)
+		if appErr != nil {
+			p.API.LogError("Failed to list user preferences", "error", appErr.Error())
+			return
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, userPreferencesKeyPrefix) {
+				continue
+			}
+			userID := strings.TrimPrefix(key, userPreferencesKeyPrefix)
+			prefs, err := p.getUserPreferences(userID)
+			if err != nil {
+				p.API.LogError("Failed to rename a link in user preferences", "error", err.Error())
+				continue
+			}
+			if !prefs.isDisabled(oldName) {
+				continue
+			}
+			prefs.setDisabled(oldName, false)
+			prefs.setDisabled(newName, true)
+			if err = p.saveUserPreferences(userID, prefs); err != nil {
+				p.API.LogError("Failed to rename a link in user preferences", "error", err.Error())
+			}
+		}
+		if len(keys) < userPreferencesPageSize {
+			return
+		}
+	}
+}
+
+func hasUserOptionalLinks(links []autolink.Autolink) bool {
+	for _, l := range links {
+		if l.IsUserOptional() && !l.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// searchUserOptionalLink finds the UserOptional link named ref, or the only
+// one whose name contains ref.
+func searchUserOptionalLink(p *Plugin, ref string) (*autolink.Autolink, error) {
+	found := []autolink.Autolink{}
+	for _, l := range p.getConfig().Links {
+		if !l.IsUserOptional() || l.Name == "" {
+			continue
+		}
+		if l.Name == ref {
+			return &l, nil
+		}
+		if strings.Contains(l.Name, ref) {
+			found = append(found, l)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, errors.Errorf("%q is not a link that can be turned off, see `/autolink mine list`", ref)
+	case 1:
+		return &found[0], nil
+	}
+
+	names := []string{}
+	for _, l := range found {
+		names = append(names, l.Name)
+	}
+	return nil, errors.Errorf("%q matched more than one link: %q", ref, names)
+}
+
+func executeMineList(p *Plugin, _ *plugin.Context, header *model.CommandArgs, _ ...string) *model.CommandResponse {
+	prefs, err := p.getUserPreferences(header.UserId)
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	text := ""
+	for _, l := range p.getConfig().Links {
+		if !l.IsUserOptional() || l.Name == "" || l.Effective().Disabled {
+			continue
+		}
+		state := "on"
+		if prefs.isDisabled(l.Name) {
+			state = "**off**"
+		}
+		text += fmt.Sprintf("- %s: %s\n", l.Name, state)
+	}
+	if text == "" {
+		return responsef("There are no links that can be turned off for your posts")
+	}
+	return responsef("Links applied to your posts:\n%s", text)
+}
+
+func executeMineEnable(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+	return executeMineEnableImpl(p, c, header, args[0], true)
+}
+
+func executeMineDisable(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+	return executeMineEnableImpl(p, c, header, args[0], false)
+}
+
+func executeMineEnableImpl(p *Plugin, c *plugin.Context, header *model.CommandArgs, ref string, enabled bool) *model.CommandResponse {
+	l, err := searchUserOptionalLink(p, ref)
+	if err != nil {
+		return responsef("%v", err)
+	}
+
+	prefs, err := p.getUserPreferences(header.UserId)
+	if err != nil {
+		return responsef("%v", err)
+	}
+	prefs.setDisabled(l.Name, !enabled)
+
+	if err = p.saveUserPreferences(header.UserId, prefs); err != nil {
+		return responsef("%v", err)
+	}
+
+	return executeMineList(p, c, header)
+}
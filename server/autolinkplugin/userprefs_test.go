@@ -0,0 +1,130 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestUserOptionalLinks(t *testing.T) {
+	conf := Config{
+		Links: []autolink.Autolink{{
+			Name:         "Glossary",
+			Pattern:      "(ESR)",
+			Template:     "[ESR](https://docs.mattermost.com/process/training.html#esr)",
+			UserOptional: true,
+		}, {
+			Name:     "Visa",
+			Pattern:  "(?P<LastFour>4\\d{3})",
+			Template: "XXXX-$LastFour",
+		}},
+	}
+
+	kv := map[string][]byte{}
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("UnregisterCommand", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return((*model.AppError)(nil))
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{}, nil)
+	api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) []byte {
+		return kv[key]
+	}, nil)
+	api.On("KVSet", mock.AnythingOfType("string"), mock.Anything).Return(func(key string, value []byte) *model.AppError {
+		kv[key] = value
+		return nil
+	})
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+
+	post := &model.Post{UserId: "userId", Message: "ESR 4123"}
+	rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post.Clone())
+	assert.Equal(t, "[ESR](https://docs.mattermost.com/process/training.html#esr) XXXX-4123", rpost.Message)
+
+	resp := executeMineDisable(p, nil, &model.CommandArgs{UserId: "userId"}, "Gloss")
+	assert.Contains(t, resp.Text, "Glossary: **off**")
+
+	rpost, _ = p.MessageWillBePosted(&plugin.Context{}, post.Clone())
+	assert.Equal(t, "ESR XXXX-4123", rpost.Message)
+
+	otherPost := &model.Post{UserId: "otherId", Message: "ESR"}
+	rpost, _ = p.MessageWillBePosted(&plugin.Context{}, otherPost)
+	assert.Equal(t, "[ESR](https://docs.mattermost.com/process/training.html#esr)", rpost.Message)
+
+	resp = executeMineDisable(p, nil, &model.CommandArgs{UserId: "userId"}, "Visa")
+	assert.Contains(t, resp.Text, "not a link that can be turned off")
+
+	resp = executeMineEnable(p, nil, &model.CommandArgs{UserId: "userId"}, "Glossary")
+	assert.Contains(t, resp.Text, "Glossary: on")
+
+	rpost, _ = p.MessageWillBePosted(&plugin.Context{}, post.Clone())
+	assert.Equal(t, "[ESR](https://docs.mattermost.com/process/training.html#esr) XXXX-4123", rpost.Message)
+}
+
+func TestMandatoryLinksAreNotUserOptional(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Links: []autolink.Autolink{{
+			Name:         "SSN",
+			Pattern:      "(?P<part1>\\d{3})-(?P<part2>\\d{2})-(?P<LastFour>\\d{4})",
+			Template:     "XXX-XX-$LastFour",
+			Action:       autolink.ActionRedact,
+			UserOptional: true,
+		}, {
+			Name:     "Glossary",
+			Pattern:  "(ESR)",
+			Template: "[ESR](https://docs.mattermost.com/process/training.html#esr)",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("LogInfo", mock.AnythingOfType("string")).Return()
+	api.On("LogWarn", "UserOptional is ignored", "link", "SSN", "error", mock.AnythingOfType("string")).Return()
+	api.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	api.On("GetUser", "userId").Return(&model.User{Id: "userId", Roles: "system_user"}, nil)
+	api.On("KVGet", userPreferencesKeyPrefix+"userId").Return([]byte(`{"disabled_links":["SSN"]}`), nil)
+	api.On("SendEphemeralPost", "userId", mock.AnythingOfType("*model.Post")).Return(&model.Post{})
+	api.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	mockAuditLog(api)
+
+	p := New()
+	p.SetAPI(api)
+	require.NoError(t, p.OnConfigurationChange())
+	api.AssertCalled(t, "LogWarn", "UserOptional is ignored", "link", "SSN", "error", mock.AnythingOfType("string"))
+
+	t.Run("the link is applied to the posts of users who turned it off", func(t *testing.T) {
+		rpost, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "userId", Message: "SSN 652-47-3356"})
+		assert.Equal(t, "SSN XXX-XX-3356", rpost.Message)
+
+		resp := executeMineDisable(p, nil, &model.CommandArgs{UserId: "userId"}, "SSN")
+		assert.Contains(t, resp.Text, "not a link that can be turned off")
+	})
+
+	t.Run("the link can't be saved UserOptional", func(t *testing.T) {
+		run := func(command string) string {
+			resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{UserId: "adminId", Command: command})
+			return resp.Text
+		}
+
+		assert.Contains(t, run("/autolink set SSN UserOptional true"), "links that redact can't be UserOptional")
+		run("/autolink set Glossary UserOptional true")
+		require.True(t, p.getConfig().Links[1].UserOptional)
+		assert.Contains(t, run("/autolink set Glossary Action reject"), "links that reject can't be UserOptional")
+		assert.Empty(t, p.getConfig().Links[1].Action)
+	})
+}
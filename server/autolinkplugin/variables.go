@@ -0,0 +1,186 @@
+package autolinkplugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// variableNameRegexp matches the names that can be referenced as
+// ${var.NAME} in a template.
+var variableNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// undefinedVariable returns the first variable the template of l references
+// that is not defined.
+func (conf *Config) undefinedVariable(l autolink.Autolink) (string, bool) {
+	for _, name := range l.VariableNames() {
+		if _, ok := autolink.LookupVariable(conf.Variables, name); !ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// linksUsingVariable returns the names of the links whose template
+// references the variable name.
+func linksUsingVariable(links []autolink.Autolink, name string) []string {
+	using := []string{}
+	for _, l := range links {
+		for _, n := range l.VariableNames() {
+			if strings.EqualFold(n, name) {
+				using = append(using, "**"+l.DisplayName()+"**")
+				break
+			}
+		}
+	}
+	return using
+}
+
+// describeUsage lists the links that use a variable, for the command output.
+func describeUsage(using []string) string {
+	if len(using) == 0 {
+		return "not used"
+	}
+	return "used by " + strings.Join(using, ", ")
+}
+
+func executeVarList(p *Plugin, _ *plugin.Context, _ *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 0 {
+		return responsef(helpText)
+	}
+	conf := p.getConfig()
+
+	names := []string{}
+	for name := range conf.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := "###### Variables\n"
+	if len(names) == 0 {
+		out += "No variables are defined. Set one with `/autolink var set <name> value...`, and use it in templates as `${var.<name>}`.\n"
+	}
+	for _, name := range names {
+		out += fmt.Sprintf("- `%s`: `%s`, %s\n", name, conf.Variables[name], describeUsage(linksUsingVariable(conf.Links, name)))
+	}
+
+	// Variables referenced by links but not defined make the links fail
+	// to compile
+	undefined := map[string]bool{}
+	for _, l := range conf.Links {
+		name, ok := conf.undefinedVariable(l)
+		if ok && !undefined[strings.ToUpper(name)] {
+			undefined[strings.ToUpper(name)] = true
+			out += fmt.Sprintf("- `%s` is not defined, %s\n", name, describeUsage(linksUsingVariable(conf.Links, name)))
+		}
+	}
+	return responsef("%s", out)
+}
+
+func executeVarSet(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) < 2 {
+		return responsef(helpText)
+	}
+	name := args[0]
+	if !variableNameRegexp.MatchString(name) {
+		return responsef("%q is not a valid variable name, use letters, digits and underscores", name)
+	}
+	value := restOfCommand(header.Command, len(args)-1)
+
+	conf := p.getConfig()
+	variables := map[string]string{}
+	old := ""
+	for n, v := range conf.Variables {
+		// Names are not case sensitive, the new name replaces the old one
+		if strings.EqualFold(n, name) {
+			old = v
+			continue
+		}
+		variables[n] = v
+	}
+	variables[name] = value
+
+	if err := saveConfigVariables(p, variables); err != nil {
+		return responsef(err.Error())
+	}
+	using := linksUsingVariable(conf.Links, name)
+	p.auditVariableChange(header.UserId, "var-set", name, old, value, using)
+
+	return responsef("Variable `%s` set to `%s`, %s.", name, value, describeUsage(using))
+}
+
+func executeVarDelete(p *Plugin, _ *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) != 1 {
+		return responsef(helpText)
+	}
+	name := args[0]
+
+	conf := p.getConfig()
+	variables := map[string]string{}
+	old, found := "", false
+	for n, v := range conf.Variables {
+		if strings.EqualFold(n, name) {
+			name, old, found = n, v, true
+			continue
+		}
+		variables[n] = v
+	}
+	if !found {
+		return responsef("Variable `%s` is not defined.", name)
+	}
+	if using := linksUsingVariable(conf.Links, name); len(using) > 0 {
+		return responsef("Variable `%s` is %s, change their templates first.", name, describeUsage(using))
+	}
+
+	if err := saveConfigVariables(p, variables); err != nil {
+		return responsef(err.Error())
+	}
+	p.auditVariableChange(header.UserId, "var-delete", name, old, "", nil)
+
+	return responsef("Variable `%s` deleted.", name)
+}
+
+// auditVariableChange records the change of a variable, and tells the
+// notification channel about it.
+func (p *Plugin) auditVariableChange(userID, action, name, old, value string, using []string) {
+	p.audit(AuditEvent{
+		Source:  auditSourceCommand,
+		UserID:  userID,
+		Action:  action,
+		Changes: []FieldChange{{Field: "var." + name, Old: old, New: value}},
+		Result:  auditResultSuccess,
+	})
+
+	if p.getConfig().NotificationChannel == "" {
+		return
+	}
+	message := fmt.Sprintf("Variable `%s` was deleted by %s.", name, p.describeActor(auditSourceCommand, userID, ""))
+	if action == "var-set" {
+		message = fmt.Sprintf("Variable `%s` was set by %s, %s.\n- `%s` → `%s`\n",
+			name, p.describeActor(auditSourceCommand, userID, ""), describeUsage(using), old, value)
+	}
+	p.notifyChannel(message)
+}
+
+func saveConfigVariables(p *Plugin, variables map[string]string) error {
+	p.UpdateConfig(func(conf *Config) {
+		conf.Variables = variables
+	})
+
+	configMap, err := p.getConfig().ToMap()
+	if err != nil {
+		return err
+	}
+
+	appErr := p.API.SavePluginConfig(configMap)
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
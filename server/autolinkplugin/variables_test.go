@@ -0,0 +1,97 @@
+package autolinkplugin
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+func TestVariables(t *testing.T) {
+	conf := Config{
+		EnableAdminCommand: true,
+		Variables:          map[string]string{"JIRA_URL": "https://jira.example.com", "UNUSED": "x"},
+		Links: []autolink.Autolink{{
+			Name:     "Jira",
+			Pattern:  "(?P<key>MM-\\d+)",
+			Template: "[$key](${var.JIRA_URL}/browse/$key)",
+		}, {
+			Name:     "Jira Cloud",
+			Pattern:  "(?P<key>CLOUD-\\d+)",
+			Template: "[$key](${var.jira_url}/browse/$key)",
+		}, {
+			Name:     "Zendesk",
+			Pattern:  "(?P<id>ZD-\\d+)",
+			Template: "[$id](${var.ZENDESK_URL}/$id)",
+		}},
+	}
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("LoadPluginConfiguration", mock.AnythingOfType("*autolinkplugin.Config")).Return(func(dest interface{}) error {
+		*dest.(*Config) = conf
+		return nil
+	})
+	mockAPI.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	mockAPI.On("LogInfo", mock.AnythingOfType("string")).Return()
+	mockAPI.On("LogError", mock.AnythingOfType("string"), "link", mock.Anything, "error", `the template uses the undefined variable "ZENDESK_URL"`).Return()
+	mockAPI.On("GetUser", "adminId").Return(&model.User{Id: "adminId", Roles: "system_admin"}, nil)
+	mockAPI.On("SavePluginConfig", mock.AnythingOfType("map[string]interface {}")).Return(nil)
+	getAuditLog := mockAuditLog(mockAPI)
+
+	p := New()
+	p.SetAPI(mockAPI)
+	require.NoError(t, p.OnConfigurationChange())
+
+	run := func(command string) string {
+		resp, _ := p.ExecuteCommand(nil, &model.CommandArgs{
+			UserId:  "adminId",
+			Command: command,
+		})
+		return resp.Text
+	}
+
+	t.Run("templates use the variables", func(t *testing.T) {
+		post, _ := p.ProcessPost(nil, &model.Post{Message: "See MM-1 and CLOUD-2"})
+		assert.Equal(t, "See [MM-1](https://jira.example.com/browse/MM-1) and [CLOUD-2](https://jira.example.com/browse/CLOUD-2)", post.Message)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		assert.Equal(t, "###### Variables\n"+
+			"- `JIRA_URL`: `https://jira.example.com`, used by **Jira**, **Jira Cloud**\n"+
+			"- `UNUSED`: `x`, not used\n"+
+			"- `ZENDESK_URL` is not defined, used by **Zendesk**\n",
+			run("/autolink var list"))
+	})
+
+	t.Run("set", func(t *testing.T) {
+		assert.Equal(t, "Variable `jira_url` set to `https://jira.example.org`, used by **Jira**, **Jira Cloud**.",
+			run("/autolink var set jira_url https://jira.example.org"))
+		assert.Equal(t, map[string]string{"jira_url": "https://jira.example.org", "UNUSED": "x"}, p.getConfig().Variables)
+
+		events := getAuditLog()
+		require.NotEmpty(t, events)
+		last := events[len(events)-1]
+		assert.Equal(t, "var-set", last.Action)
+		assert.Equal(t, []FieldChange{{Field: "var.jira_url", Old: "https://jira.example.com", New: "https://jira.example.org"}}, last.Changes)
+
+		assert.Equal(t, "Variable `ZENDESK_URL` set to `https://zendesk.example.com/tickets`, used by **Zendesk**.",
+			run(`/autolink var set ZENDESK_URL https://zendesk.example.com/tickets`))
+		assert.Contains(t, run("/autolink var set ZENDESK-URL x"), "not a valid variable name")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.Equal(t, "Variable `jira_url` is used by **Jira**, **Jira Cloud**, change their templates first.",
+			run("/autolink var delete JIRA_URL"))
+		assert.Equal(t, "Variable `UNUSED` deleted.", run("/autolink var delete unused"))
+		assert.Equal(t, "Variable `UNUSED` is not defined.", run("/autolink var delete UNUSED"))
+		assert.Len(t, p.getConfig().Variables, 2)
+
+		events := getAuditLog()
+		assert.Equal(t, "var-delete", events[len(events)-1].Action)
+	})
+}
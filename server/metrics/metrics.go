@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const namespace = "autolink"
+
+// DefaultBuckets are the upper bounds, in seconds, used for the latency
+// histograms. Post processing is expected to take well under a millisecond,
+// so the buckets are skewed towards the low end.
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// Metrics collects the plugin's runtime metrics and renders them in the
+// Prometheus text exposition format. It is safe for concurrent use, and
+// observations don't wait on each other.
+type Metrics struct {
+	// lock serializes the changes of the set of links.
+	lock sync.Mutex
+
+	processPost *histogram
+	// linkEvaluation has the histograms of the links set with SetLinks, by
+	// display name. It is replaced as a whole when the links change.
+	linkEvaluation        atomic.Pointer[map[string]*histogram]
+	postsRewritten        atomic.Uint64
+	scopeResolutionErrors atomic.Uint64
+	authorLookupErrors    atomic.Uint64
+}
+
+// New creates an empty set of metrics.
+func New() *Metrics {
+	m := &Metrics{
+		processPost: newHistogram(DefaultBuckets),
+	}
+	m.linkEvaluation.Store(&map[string]*histogram{})
+	return m
+}
+
+// SetLinks sets the display names of the links whose evaluation is
+// observed. The histograms of the links that are not in links any more are
+// dropped, the others are kept.
+func (m *Metrics) SetLinks(links []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	old := *m.linkEvaluation.Load()
+	current := make(map[string]*histogram, len(links))
+	for _, link := range links {
+		h := old[link]
+		if h == nil {
+			h = newHistogram(DefaultBuckets)
+		}
+		current[link] = h
+	}
+	m.linkEvaluation.Store(&current)
+}
+
+// ObserveProcessPost records the time it took to process a single post.
+func (m *Metrics) ObserveProcessPost(d time.Duration) {
+	m.processPost.observe(d.Seconds())
+}
+
+// ObserveLinkEvaluation records the time it took to evaluate a single link
+// against a fragment of a post. Links that were not set with SetLinks are
+// not observed.
+func (m *Metrics) ObserveLinkEvaluation(link string, d time.Duration) {
+	if h := (*m.linkEvaluation.Load())[link]; h != nil {
+		h.observe(d.Seconds())
+	}
+}
+
+// IncPostsRewritten counts a post whose message was changed by the plugin.
+func (m *Metrics) IncPostsRewritten() {
+	m.postsRewritten.Add(1)
+}
+
+// PostsRewritten returns the number of posts whose message was changed since
+// the plugin started.
+func (m *Metrics) PostsRewritten() uint64 {
+	return m.postsRewritten.Load()
+}
+
+// IncScopeResolutionErrors counts a failure to resolve a post's team and channel.
+func (m *Metrics) IncScopeResolutionErrors() {
+	m.scopeResolutionErrors.Add(1)
+}
+
+// IncAuthorLookupErrors counts a failure to look up a post's author.
+func (m *Metrics) IncAuthorLookupErrors() {
+	m.authorLookupErrors.Add(1)
+}
+
+// WriteTo writes all metrics to w in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	b := &strings.Builder{}
+
+	name := namespace + "_process_post_duration_seconds"
+	writeHeader(b, name, "histogram", "Time spent rewriting a single post.")
+	m.processPost.write(b, name, "")
+
+	name = namespace + "_link_evaluation_duration_seconds"
+	writeHeader(b, name, "histogram", "Time spent evaluating a single link against a fragment of a post.")
+	linkEvaluation := *m.linkEvaluation.Load()
+	links := make([]string, 0, len(linkEvaluation))
+	for link := range linkEvaluation {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+	for _, link := range links {
+		linkEvaluation[link].write(b, name, fmt.Sprintf(`link="%s"`, escapeLabelValue(link)))
+	}
+
+	writeCounter(b, namespace+"_posts_rewritten_total",
+		"Number of posts whose message was changed.", m.postsRewritten.Load())
+	writeCounter(b, namespace+"_scope_resolution_errors_total",
+		"Number of failures to resolve the team and channel of a post.", m.scopeResolutionErrors.Load())
+	writeCounter(b, namespace+"_author_lookup_errors_total",
+		"Number of failures to look up the author of a post.", m.authorLookupErrors.Load())
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// histogram is a Prometheus histogram whose observations are lock-free.
+type histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	// sumBits has the bits of the float64 sum of the observations.
+	sumBits atomic.Uint64
+	count   atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i].Add(1)
+		}
+	}
+	for {
+		old := h.sumBits.Load()
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			break
+		}
+	}
+	h.count.Add(1)
+}
+
+func (h *histogram) write(b *strings.Builder, name, labels string) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+	for i, upper := range h.buckets {
+		fmt.Fprintf(b, "%s_bucket{%sle=\"%v\"} %d\n", name, prefix, upper, h.counts[i].Load())
+	}
+	fmt.Fprintf(b, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count.Load())
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(b, "%s_sum%s %v\n", name, labels, math.Float64frombits(h.sumBits.Load()))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, labels, h.count.Load())
+}
+
+func writeHeader(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, typ)
+}
+
+func writeCounter(b *strings.Builder, name, help string, value uint64) {
+	writeHeader(b, name, "counter", help)
+	fmt.Fprintf(b, "%s %d\n", name, value)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := New()
+	m.SetLinks([]string{`Jira "MM"`})
+	m.ObserveProcessPost(200 * time.Microsecond)
+	m.ObserveProcessPost(2 * time.Second)
+	m.ObserveLinkEvaluation(`Jira "MM"`, 50*time.Microsecond)
+	m.IncPostsRewritten()
+	m.IncScopeResolutionErrors()
+	m.IncAuthorLookupErrors()
+	m.IncAuthorLookupErrors()
+
+	b := &strings.Builder{}
+	_, err := m.WriteTo(b)
+	require.NoError(t, err)
+	out := b.String()
+
+	for _, expected := range []string{
+		"# TYPE autolink_process_post_duration_seconds histogram\n",
+		"autolink_process_post_duration_seconds_bucket{le=\"0.0001\"} 0\n",
+		"autolink_process_post_duration_seconds_bucket{le=\"0.0005\"} 1\n",
+		"autolink_process_post_duration_seconds_bucket{le=\"5\"} 2\n",
+		"autolink_process_post_duration_seconds_bucket{le=\"+Inf\"} 2\n",
+		"autolink_process_post_duration_seconds_count 2\n",
+		"autolink_link_evaluation_duration_seconds_bucket{link=\"Jira \\\"MM\\\"\",le=\"0.0001\"} 1\n",
+		"autolink_link_evaluation_duration_seconds_count{link=\"Jira \\\"MM\\\"\"} 1\n",
+		"# TYPE autolink_posts_rewritten_total counter\n",
+		"autolink_posts_rewritten_total 1\n",
+		"autolink_scope_resolution_errors_total 1\n",
+		"autolink_author_lookup_errors_total 2\n",
+	} {
+		assert.Contains(t, out, expected)
+	}
+}
+
+func TestSetLinks(t *testing.T) {
+	m := New()
+	m.SetLinks([]string{"Jira", "GitHub"})
+	m.ObserveLinkEvaluation("Jira", 50*time.Microsecond)
+	m.ObserveLinkEvaluation("GitHub", 50*time.Microsecond)
+	m.ObserveLinkEvaluation("Unknown", 50*time.Microsecond)
+
+	m.SetLinks([]string{"Jira", "Confluence"})
+	m.ObserveLinkEvaluation("Jira", 2*time.Millisecond)
+
+	b := &strings.Builder{}
+	_, err := m.WriteTo(b)
+	require.NoError(t, err)
+	out := b.String()
+
+	assert.Contains(t, out, "autolink_link_evaluation_duration_seconds_count{link=\"Jira\"} 2\n", "kept links keep their observations")
+	assert.Contains(t, out, "autolink_link_evaluation_duration_seconds_count{link=\"Confluence\"} 0\n")
+	assert.NotContains(t, out, "GitHub", "removed links are dropped")
+	assert.NotContains(t, out, "Unknown")
+}
+
+func TestConcurrentObservations(t *testing.T) {
+	m := New()
+	m.SetLinks([]string{"Jira"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.ObserveLinkEvaluation("Jira", time.Millisecond)
+				m.ObserveProcessPost(time.Millisecond)
+			}
+		}()
+	}
+	m.SetLinks([]string{"Jira", "GitHub"})
+	wg.Wait()
+
+	b := &strings.Builder{}
+	_, err := m.WriteTo(b)
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), "autolink_link_evaluation_duration_seconds_count{link=\"Jira\"} 800\n")
+	assert.Contains(t, b.String(), "autolink_process_post_duration_seconds_sum 0.8")
+}
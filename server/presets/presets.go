@@ -0,0 +1,286 @@
+// Package presets has ready-made links for common services and masking rules,
+// parameterized with the addresses and keys of each server.
+package presets
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+)
+
+// Param is a parameter of a preset.
+type Param struct {
+	Name        string
+	Description string
+	// Default is used when the parameter is not given. Parameters without a
+	// default are required.
+	Default string
+}
+
+// Preset builds a link from its parameters.
+type Preset struct {
+	Name        string
+	Description string
+	Params      []Param
+
+	build func(params map[string]string) (autolink.Autolink, error)
+}
+
+var (
+	jiraKeyRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	accountRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+)
+
+var presets = []Preset{
+	{
+		Name:        "jira",
+		Description: "Jira issue keys, e.g. `MM-1234`, and links to Jira issues, as links to the issues.",
+		Params: []Param{
+			{Name: "baseURL", Description: "The address of Jira, e.g. `https://mycompany.atlassian.net`."},
+			{Name: "projectKeys", Description: "The keys of the projects, separated by commas, e.g. `MM,PLT`."},
+		},
+		build: func(params map[string]string) (autolink.Autolink, error) {
+			baseURL, err := parseBaseURL(params["baseURL"])
+			if err != nil {
+				return autolink.Autolink{}, err
+			}
+			keys := []string{}
+			for _, key := range strings.Split(params["projectKeys"], ",") {
+				key = strings.TrimSpace(key)
+				if !jiraKeyRegexp.MatchString(key) {
+					return autolink.Autolink{}, errors.Errorf("%q is not a Jira project key", key)
+				}
+				keys = append(keys, key)
+			}
+			return autolink.Autolink{
+				Name:     "Jira",
+				Pattern:  `(?:` + regexp.QuoteMeta(baseURL+"/browse/") + `)?(?P<key>(?:` + strings.Join(keys, "|") + `)-\d+)`,
+				Template: "[${key}](" + templateLiteral(baseURL) + "/browse/${key})",
+			}, nil
+		},
+	},
+	{
+		Name:        "github",
+		Description: "Links to the pull requests and issues of a GitHub organization, as `repo#123`.",
+		Params: []Param{
+			{Name: "org", Description: "The organization, e.g. `mattermost`."},
+			{Name: "baseURL", Description: "The address of GitHub, for GitHub Enterprise.", Default: "https://github.com"},
+		},
+		build: func(params map[string]string) (autolink.Autolink, error) {
+			baseURL, err := parseBaseURL(params["baseURL"])
+			if err != nil {
+				return autolink.Autolink{}, err
+			}
+			org := params["org"]
+			if !accountRegexp.MatchString(org) {
+				return autolink.Autolink{}, errors.Errorf("%q is not a GitHub organization", org)
+			}
+			orgURL := baseURL + "/" + org
+			return autolink.Autolink{
+				Name:     "GitHub",
+				Pattern:  regexp.QuoteMeta(orgURL) + `/(?P<repo>[\w.-]+)/(?P<kind>pull|issues)/(?P<id>\d+)`,
+				Template: "[${repo}#${id}](" + templateLiteral(orgURL) + "/${repo}/${kind}/${id})",
+			}, nil
+		},
+	},
+	{
+		Name:        "gitlab",
+		Description: "Links to GitLab merge requests and issues, as `group/project#123`.",
+		Params: []Param{
+			{Name: "baseURL", Description: "The address of GitLab, for self-managed GitLab.", Default: "https://gitlab.com"},
+		},
+		build: func(params map[string]string) (autolink.Autolink, error) {
+			baseURL, err := parseBaseURL(params["baseURL"])
+			if err != nil {
+				return autolink.Autolink{}, err
+			}
+			return autolink.Autolink{
+				Name:     "GitLab",
+				Pattern:  regexp.QuoteMeta(baseURL) + `/(?P<project>[\w.-]+(?:/[\w.-]+)+)/-/(?P<kind>merge_requests|issues)/(?P<id>\d+)`,
+				Template: "[${project}#${id}](" + templateLiteral(baseURL) + "/${project}/-/${kind}/${id})",
+			}, nil
+		},
+	},
+	{
+		Name:        "permalink",
+		Description: "Permalinks to posts of this server, as relative links that work in the mobile app too.",
+		Params: []Param{
+			{Name: "siteURL", Description: "The address of the server, e.g. `https://community.mattermost.com`."},
+		},
+		build: func(params map[string]string) (autolink.Autolink, error) {
+			siteURL, err := parseBaseURL(params["siteURL"])
+			if err != nil {
+				return autolink.Autolink{}, err
+			}
+			return autolink.Autolink{
+				Name:     "Permalink",
+				Pattern:  regexp.QuoteMeta(siteURL) + `/(?P<team>[a-z0-9-]+)/pl/(?P<id>[a-z0-9]{26})`,
+				Template: "[<jump to convo>](/${team}/pl/${id})",
+			}, nil
+		},
+	},
+	{
+		Name:        "cve",
+		Description: "CVE identifiers, e.g. `CVE-2024-3094`, as links to the CVE records.",
+		build: func(map[string]string) (autolink.Autolink, error) {
+			return autolink.Autolink{
+				Name:     "CVE",
+				Pattern:  `(?P<id>CVE-\d{4}-\d{4,7})`,
+				Template: "[${id}](https://www.cve.org/CVERecord?id=${id})",
+			}, nil
+		},
+	},
+	{
+		Name:        "rfc",
+		Description: "RFC numbers, e.g. `RFC 9110` or `RFC9110`, as links to the RFCs.",
+		build: func(map[string]string) (autolink.Autolink, error) {
+			return autolink.Autolink{
+				Name:     "RFC",
+				Pattern:  `RFC ?(?P<number>\d{1,5})`,
+				Template: "[RFC ${number}](https://www.rfc-editor.org/rfc/rfc${number})",
+			}, nil
+		},
+	},
+	{
+		Name:        "sentry",
+		Description: "Links to Sentry issues, as `Sentry issue 123`.",
+		build: func(map[string]string) (autolink.Autolink, error) {
+			return autolink.Autolink{
+				Name:     "Sentry",
+				Pattern:  `https://(?P<org>[\w-]+)\.sentry\.io/issues/(?P<id>\d+)/?(?:\?\S*)?`,
+				Template: "[Sentry issue ${id}](https://${org}.sentry.io/issues/${id}/)",
+			}, nil
+		},
+	},
+	{
+		Name:        "pagerduty",
+		Description: "Links to PagerDuty incidents, as `PagerDuty incident Q1ABC`.",
+		build: func(map[string]string) (autolink.Autolink, error) {
+			return autolink.Autolink{
+				Name:     "PagerDuty",
+				Pattern:  `https://(?P<account>[\w-]+)\.pagerduty\.com/incidents/(?P<id>[A-Z0-9]+)`,
+				Template: "[PagerDuty incident ${id}](https://${account}.pagerduty.com/incidents/${id})",
+			}, nil
+		},
+	},
+	{
+		Name:        "servicenow",
+		Description: "ServiceNow record numbers, e.g. `INC0012345` or `CHG0001234`, as links to the records.",
+		Params: []Param{
+			{Name: "instance", Description: "The name of the instance, e.g. `mycompany` for `mycompany.service-now.com`."},
+		},
+		build: func(params map[string]string) (autolink.Autolink, error) {
+			instance := params["instance"]
+			if !accountRegexp.MatchString(instance) {
+				return autolink.Autolink{}, errors.Errorf("%q is not a ServiceNow instance name", instance)
+			}
+			return autolink.Autolink{
+				Name:     "ServiceNow",
+				Pattern:  `(?P<number>(?:INC|CHG|PRB|RITM|REQ|SCTASK|TASK)\d{7})`,
+				Template: "[${number}](https://" + instance + ".service-now.com/text_search_exact_match.do?sysparm_search=${number})",
+			}, nil
+		},
+	},
+	masking("visa", "Visa", "Visa card numbers",
+		`(?P<VISA>(?P<part1>4\d{3})[ -]?(?P<part2>\d{4})[ -]?(?P<part3>\d{4})[ -]?(?P<LastFour>[0-9]{4}))`,
+		"VISA XXXX-XXXX-XXXX-$LastFour"),
+	masking("mastercard", "MasterCard", "MasterCard card numbers",
+		`(?P<MasterCard>(?P<part1>5[1-5]\d{2})[ -]?(?P<part2>\d{4})[ -]?(?P<part3>\d{4})[ -]?(?P<LastFour>[0-9]{4}))`,
+		"MasterCard XXXX-XXXX-XXXX-$LastFour"),
+	masking("amex", "AMEX", "American Express card numbers",
+		`(?P<AMEX>(?P<part1>3[47]\d{2})[ -]?(?P<part2>\d{6})[ -]?(?P<part3>\d)(?P<LastFour>[0-9]{4}))`,
+		"American Express XXXX-XXXXXX-X$LastFour"),
+	masking("discover", "Discover", "Discover card numbers",
+		`(?P<Discover>(?P<part1>6011)[ -]?(?P<part2>\d{4})[ -]?(?P<part3>\d{4})[ -]?(?P<LastFour>[0-9]{4}))`,
+		"Discover XXXX-XXXX-XXXX-$LastFour"),
+	masking("ssn", "SSN", "US social security numbers",
+		`(?P<SSN>(?P<part1>\d{3})[ -]?(?P<part2>\d{2})[ -]?(?P<LastFour>[0-9]{4}))`,
+		"XXX-XX-$LastFour"),
+}
+
+// masking returns a preset that redacts all but the last four digits of a
+// number.
+func masking(name, linkName, description, pattern, template string) Preset {
+	return Preset{
+		Name:        "masking:" + name,
+		Description: description + ", redacted except for the last four digits.",
+		build: func(map[string]string) (autolink.Autolink, error) {
+			processBotPosts := true
+			return autolink.Autolink{
+				Name:            linkName,
+				Pattern:         pattern,
+				Template:        template,
+				ProcessBotPosts: &processBotPosts,
+				Action:          autolink.ActionRedact,
+			}, nil
+		},
+	}
+}
+
+// List returns all the presets.
+func List() []Preset {
+	return append([]Preset(nil), presets...)
+}
+
+// Get returns the preset named name.
+func Get(name string) (Preset, bool) {
+	for _, preset := range presets {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+// Build returns the link of the preset for params, and checks that it
+// compiles. Parameters that are not given take their default value.
+func (p Preset) Build(params map[string]string) (autolink.Autolink, error) {
+	values := map[string]string{}
+	for _, param := range p.Params {
+		values[param.Name] = param.Default
+	}
+	for name, value := range params {
+		if _, ok := values[name]; !ok {
+			return autolink.Autolink{}, errors.Errorf("preset %s has no parameter %q", p.Name, name)
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			values[name] = value
+		}
+	}
+	for _, param := range p.Params {
+		if values[param.Name] == "" {
+			return autolink.Autolink{}, errors.Errorf("preset %s requires %s: %s", p.Name, param.Name, param.Description)
+		}
+	}
+
+	l, err := p.build(values)
+	if err != nil {
+		return autolink.Autolink{}, errors.Wrapf(err, "invalid parameters for preset %s", p.Name)
+	}
+
+	compiled := l
+	compiled.Disabled = false
+	if err = compiled.Compile(); err != nil {
+		return autolink.Autolink{}, errors.Wrapf(err, "preset %s built an invalid pattern", p.Name)
+	}
+	return l, nil
+}
+
+// parseBaseURL checks that s is an http(s) address, and returns it without
+// a trailing slash.
+func parseBaseURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.Errorf("%q is not an http or https address", s)
+	}
+	return strings.TrimRight(s, "/"), nil
+}
+
+// templateLiteral escapes s to be used as is in a template.
+func templateLiteral(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
@@ -0,0 +1,143 @@
+package presets_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
+	"github.com/mattermost-community/mattermost-plugin-autolink/server/presets"
+)
+
+func TestPresets(t *testing.T) {
+	for _, tc := range []struct {
+		Preset   string
+		Params   map[string]string
+		Message  string
+		Expected string
+	}{
+		{
+			"jira", map[string]string{"baseURL": "https://mattermost.atlassian.net/", "projectKeys": "MM, PLT"},
+			"Welcome MM-12345 and https://mattermost.atlassian.net/browse/PLT-1, not XMM-1",
+			"Welcome [MM-12345](https://mattermost.atlassian.net/browse/MM-12345) and [PLT-1](https://mattermost.atlassian.net/browse/PLT-1), not XMM-1",
+		},
+		{
+			"github", map[string]string{"org": "mattermost"},
+			"See https://github.com/mattermost/mattermost-plugin-autolink/pull/123 and https://github.com/mattermost/mattermost/issues/4.",
+			"See [mattermost-plugin-autolink#123](https://github.com/mattermost/mattermost-plugin-autolink/pull/123) and [mattermost#4](https://github.com/mattermost/mattermost/issues/4).",
+		},
+		{
+			"github", map[string]string{"org": "eng", "baseURL": "https://git.example.com"},
+			"See https://git.example.com/eng/api/pull/5",
+			"See [api#5](https://git.example.com/eng/api/pull/5)",
+		},
+		{
+			"gitlab", nil,
+			"See https://gitlab.com/group/sub/project/-/merge_requests/12",
+			"See [group/sub/project#12](https://gitlab.com/group/sub/project/-/merge_requests/12)",
+		},
+		{
+			"permalink", map[string]string{"siteURL": "https://community.mattermost.com"},
+			"See https://community.mattermost.com/core/pl/abcdefghijklmnopqrstuvwxyz",
+			"See [<jump to convo>](/core/pl/abcdefghijklmnopqrstuvwxyz)",
+		},
+		{
+			"cve", nil,
+			"Fixes CVE-2024-3094.",
+			"Fixes [CVE-2024-3094](https://www.cve.org/CVERecord?id=CVE-2024-3094).",
+		},
+		{
+			"rfc", nil,
+			"As in RFC9110",
+			"As in [RFC 9110](https://www.rfc-editor.org/rfc/rfc9110)",
+		},
+		{
+			"sentry", nil,
+			"Crash https://acme.sentry.io/issues/4509/?project=2",
+			"Crash [Sentry issue 4509](https://acme.sentry.io/issues/4509/)",
+		},
+		{
+			"pagerduty", nil,
+			"Paged https://acme.pagerduty.com/incidents/Q1ABC2",
+			"Paged [PagerDuty incident Q1ABC2](https://acme.pagerduty.com/incidents/Q1ABC2)",
+		},
+		{
+			"servicenow", map[string]string{"instance": "acme"},
+			"Opened INC0012345",
+			"Opened [INC0012345](https://acme.service-now.com/text_search_exact_match.do?sysparm_search=INC0012345)",
+		},
+		{
+			"masking:visa", nil,
+			"Card 4111-1111-1111-1234 please",
+			"Card VISA XXXX-XXXX-XXXX-1234 please",
+		},
+		{
+			"MASKING:SSN", nil,
+			"SSN 652-47-3356",
+			"SSN XXX-XX-3356",
+		},
+	} {
+		t.Run(tc.Preset, func(t *testing.T) {
+			preset, ok := presets.Get(tc.Preset)
+			require.True(t, ok)
+
+			l, err := preset.Build(tc.Params)
+			require.NoError(t, err)
+			assert.False(t, l.Disabled)
+			assert.NotEmpty(t, l.Name)
+
+			require.NoError(t, l.Compile())
+			assert.Equal(t, tc.Expected, l.Replace(tc.Message))
+
+			if strings.HasPrefix(preset.Name, "masking:") {
+				assert.Equal(t, autolink.ActionRedact, l.Action)
+			} else {
+				assert.Empty(t, l.Action)
+			}
+		})
+	}
+}
+
+func TestPresetErrors(t *testing.T) {
+	for _, tc := range []struct {
+		Name          string
+		Preset        string
+		Params        map[string]string
+		ExpectedError string
+	}{
+		{"missing parameter", "jira", map[string]string{"baseURL": "https://jira.example.com"},
+			"preset jira requires projectKeys"},
+		{"unknown parameter", "cve", map[string]string{"baseURL": "https://cve.example.com"},
+			`preset cve has no parameter "baseURL"`},
+		{"invalid address", "jira", map[string]string{"baseURL": "jira.example.com", "projectKeys": "MM"},
+			`"jira.example.com" is not an http or https address`},
+		{"invalid project key", "jira", map[string]string{"baseURL": "https://jira.example.com", "projectKeys": "MM,(.*)"},
+			`"(.*)" is not a Jira project key`},
+		{"invalid organization", "github", map[string]string{"org": "a/b"},
+			`"a/b" is not a GitHub organization`},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			preset, ok := presets.Get(tc.Preset)
+			require.True(t, ok)
+
+			_, err := preset.Build(tc.Params)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.ExpectedError)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	names := map[string]bool{}
+	for _, preset := range presets.List() {
+		assert.False(t, names[preset.Name], "duplicate preset %s", preset.Name)
+		names[preset.Name] = true
+		assert.NotEmpty(t, preset.Description)
+	}
+	assert.True(t, names["masking:visa"])
+
+	_, ok := presets.Get("unknown")
+	assert.False(t, ok)
+}